@@ -0,0 +1,385 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package piglex
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	INITIAL_STATE = "_INIT"
+)
+
+// scope modes (%only / %except)
+const (
+	SCOPE_ALL = iota
+	SCOPE_ONLY
+	SCOPE_EXCEPT
+)
+
+// action kinds
+const (
+	ACTION_RETURN = iota
+	ACTION_STATE
+	ACTION_MACRO
+)
+
+// macro parameter kinds
+const (
+	PARAM_TOKEN = iota
+	PARAM_VALUE
+	PARAM_CHAR
+)
+
+//
+// Spec is the parsed content of a .pigl file
+//
+type Spec struct {
+	File       string
+	Directives []*Directive
+	Includes   []*Include
+	Output     string
+	Package    string // %option package=name, main by default
+	Prefix     string // %option prefix=name
+	Backend    string // %option backend=name
+	Caseless   bool
+	Wrap       bool
+	Debug      bool
+	NoUnicode  bool
+	Defs       []*Definition
+	Tokens     []*Decl
+	States     []*Decl
+	Rules      []*LexRule
+	TokenIDs   map[string]int // ids imported from a token table
+
+	sources map[string][]string
+}
+
+//
+// Directive is a %command line, as found in the source
+//
+type Directive struct {
+	Name string
+	Args []string
+	Pos  Pos
+
+	argPos []Pos
+}
+
+//
+// Include is Go code included with %include, to be injected
+// in the generated lexer
+//
+type Include struct {
+	Name string
+	File string
+	Code string
+	Pos  Pos
+}
+
+//
+// Definition is a named regexp fragment (%define or %alias),
+// used as {NAME} in rules and other definitions
+//
+type Definition struct {
+	Name   string
+	Regexp string
+	Pos    Pos
+
+	regexpPos Pos
+}
+
+//
+// Decl is a %token or %state declaration
+//
+type Decl struct {
+	Name string
+	Pos  Pos
+}
+
+//
+// Scope is the start-condition scope of a rule (%only / %except)
+//
+type Scope struct {
+	Mode   int
+	States []string
+	Pos    Pos
+}
+
+//
+// LexRule is a regular expression and its list of actions.
+// States lists the states the rule is active in, once the
+// scope is resolved, and Pattern is the regexp to compile,
+// once the definitions are expanded
+//
+type LexRule struct {
+	Regexp  string
+	Pattern string
+	Scope   *Scope
+	States  []string
+	Actions []*Action
+	Pos     Pos
+}
+
+//
+// Action is a single action of a rule (return TOKEN, state _STATE,
+// or a macro call with its parameters). Char is set for the return
+// of a literal character ('+'), named as quoted.
+//
+type Action struct {
+	Kind   int
+	Name   string
+	Char   rune
+	Params []*Param
+	Pos    Pos
+}
+
+//
+// Param is a parameter of a macro call: token, value or 'c'
+//
+type Param struct {
+	Kind int
+	Char rune
+	Pos  Pos
+}
+
+var globalScope = &Scope{
+	Mode: SCOPE_ALL,
+}
+
+func newSpec(file string) *Spec {
+	return &Spec{
+		File: file,
+		States: []*Decl{
+			{Name: INITIAL_STATE},
+		},
+	}
+}
+
+//
+// setLine keeps a line of source, for the diagnostics
+//
+func (spec *Spec) setLine(file string, line int, text string) {
+	if spec.sources == nil {
+		spec.sources = map[string][]string{}
+	}
+	lines := spec.sources[file]
+	for len(lines) < line {
+		lines = append(lines, "")
+	}
+	lines[line-1] = text
+	spec.sources[file] = lines
+}
+
+//
+// ArgPos returns the position of an argument of the directive
+//
+func (directive *Directive) ArgPos(i int) Pos {
+	if i < len(directive.argPos) {
+		return directive.argPos[i]
+	}
+	return directive.Pos
+}
+
+func (spec *Spec) token(name string) *Decl {
+	for _, decl := range spec.Tokens {
+		if decl.Name == name {
+			return decl
+		}
+	}
+	return nil
+}
+
+func (spec *Spec) state(name string) *Decl {
+	for _, decl := range spec.States {
+		if decl.Name == name {
+			return decl
+		}
+	}
+	return nil
+}
+
+//
+// Contains tells if a state is part of the scope
+//
+func (scope *Scope) Contains(state string) bool {
+	if scope.Mode == SCOPE_ALL {
+		return true
+	}
+	found := false
+	for _, name := range scope.States {
+		if name == state {
+			found = true
+			break
+		}
+	}
+	if scope.Mode == SCOPE_EXCEPT {
+		return !found
+	}
+	return found
+}
+
+//
+// resolveScopes tags each rule with the states it is active in:
+// only the listed states for %only, all declared states but the
+// listed ones for %except
+//
+func (spec *Spec) resolveScopes() {
+	for _, rule := range spec.Rules {
+		rule.States = []string{}
+		for _, decl := range spec.States {
+			if rule.Scope.Contains(decl.Name) {
+				rule.States = append(rule.States, decl.Name)
+			}
+		}
+	}
+}
+
+//
+// RulesFor returns the indexes of the rules active in a state,
+// in order of definition
+//
+func (spec *Spec) RulesFor(state string) []int {
+	list := []int{}
+	for i, rule := range spec.Rules {
+		for _, name := range rule.States {
+			if name == state {
+				list = append(list, i)
+				break
+			}
+		}
+	}
+	return list
+}
+
+//
+// Dump writes a readable version of the spec
+//
+func (spec *Spec) Dump(w io.Writer) {
+	fmt.Fprintf(w, "spec %s\n", spec.File)
+	for _, directive := range spec.Directives {
+		fmt.Fprintf(w, "  %%%s %s\n", directive.Name, strings.Join(directive.Args, ", "))
+	}
+	for _, include := range spec.Includes {
+		fmt.Fprintf(w, "  include %s (%d bytes)\n", include.File, len(include.Code))
+	}
+	for _, def := range spec.Defs {
+		fmt.Fprintf(w, "  define %s %q (%s)\n", def.Name, def.Regexp, def.Pos)
+	}
+	for _, rule := range spec.Rules {
+		fmt.Fprintf(w, "  rule %q %s [%s] (%s)\n", rule.Regexp, rule.Scope, strings.Join(rule.States, ", "), rule.Pos)
+		if rule.Pattern != rule.Regexp {
+			fmt.Fprintf(w, "    pattern %q\n", rule.Pattern)
+		}
+		for _, action := range rule.Actions {
+			fmt.Fprintf(w, "    %s\n", action)
+		}
+	}
+}
+
+func (scope *Scope) String() string {
+	switch scope.Mode {
+	case SCOPE_ONLY:
+		return "only " + strings.Join(scope.States, ", ")
+	case SCOPE_EXCEPT:
+		return "except " + strings.Join(scope.States, ", ")
+	}
+	return "all"
+}
+
+func (action *Action) String() string {
+	switch action.Kind {
+	case ACTION_RETURN:
+		return "return " + action.Name
+	case ACTION_STATE:
+		return "state " + action.Name
+	case ACTION_MACRO:
+		params := make([]string, len(action.Params))
+		for i, param := range action.Params {
+			params[i] = param.String()
+		}
+		return action.Name + "(" + strings.Join(params, ", ") + ")"
+	}
+	return action.Name
+}
+
+func (param *Param) String() string {
+	switch param.Kind {
+	case PARAM_TOKEN:
+		return "token"
+	case PARAM_VALUE:
+		return "value"
+	}
+	return strconv.QuoteRune(param.Char)
+}
+
+//
+// Return returns the action returning the token of the rule, if any
+//
+func (rule *LexRule) Return() *Action {
+	for _, action := range rule.Actions {
+		if action.Kind == ACTION_RETURN {
+			return action
+		}
+	}
+	return nil
+}
+
+//
+// parseArgs splits directive arguments on blanks and commas,
+// removing quotes around strings. It also returns the offset
+// (in runes) of each argument in the value.
+//
+func parseArgs(value string) ([]string, []int) {
+	args := []string{}
+	offsets := []int{}
+	current := ""
+	quoted := false
+	add := func(i int) {
+		args = append(args, current)
+		offsets = append(offsets, i-len([]rune(current)))
+		current = ""
+	}
+	i := 0
+	for _, c := range value {
+		switch {
+		case c == '"':
+			if quoted {
+				add(i)
+			}
+			quoted = !quoted
+		case quoted:
+			current += string(c)
+		case c == ',' || strings.IndexRune(BLANKSPACES, c) >= 0:
+			if current != "" {
+				add(i)
+			}
+		default:
+			current += string(c)
+		}
+		i++
+	}
+	if current != "" {
+		add(i)
+	}
+	return args, offsets
+}
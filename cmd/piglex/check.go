@@ -0,0 +1,55 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/peergum/piglex"
+)
+
+var checkCommand = &command{
+	name:  "check",
+	short: "validate the spec",
+	help: `Check parses and validates the spec, and makes sure code can be generated
+for it (rule regexps compiling, macros found in the included sources, backend
+supported), without writing anything. It exits with 1 if the spec has errors.`,
+	run: runCheck,
+}
+
+func runCheck(flags *flag.FlagSet, args []string) int {
+	options := newSpecFlags(flags)
+	backend := flags.String("b", "", "Backend to check the spec against: regexp or dfa")
+	if ok, code := parse(flags, args); !ok {
+		return code
+	}
+
+	spec, code := options.load()
+	if spec == nil {
+		return code
+	}
+	if _, err := piglex.Generate(spec, piglex.Options{Backend: *backend}); err != nil {
+		printError(err)
+		return EXIT_ERRORS
+	}
+	fmt.Fprintf(os.Stderr, "%s: ok\n", spec.File)
+	return EXIT_OK
+}
@@ -0,0 +1,101 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/peergum/piglex"
+)
+
+var coverageCommand = &command{
+	name:  "coverage",
+	short: "report the rules fired on a corpus",
+	help: `Coverage runs the spec on the input files given (stdin if none), as tokenize
+does, and reports how many times each rule fired in each state, the rules
+that never fired and the states never entered. Macro actions are not run.
+
+    piglex coverage -l sample.pigl testdata/*.basic
+
+Lexers generated with piglex generate -coverage print the same report.`,
+	run: runCoverage,
+}
+
+func runCoverage(flags *flag.FlagSet, args []string) int {
+	options := newSpecFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return EXIT_OK
+		}
+		return EXIT_USAGE
+	}
+	inputs := flags.Args()
+	if len(inputs) == 0 {
+		inputs = []string{STDIO}
+	}
+
+	spec, code := options.load()
+	if spec == nil {
+		return code
+	}
+	coverage := piglex.NewCoverage(spec)
+	code = EXIT_OK
+	for _, input := range inputs {
+		if err := cover(spec, coverage, input); err != nil {
+			printError(err)
+			code = EXIT_ERRORS
+		}
+	}
+	coverage.Report(os.Stdout)
+	return code
+}
+
+//
+// cover runs the spec on an input, counting the rules fired
+//
+func cover(spec *piglex.Spec, coverage *piglex.Coverage, input string) error {
+	var source io.Reader = os.Stdin
+	name := "<stdin>"
+	if input != STDIO {
+		file, err := os.Open(input)
+		if err != nil {
+			return fmt.Errorf("can't open source file %s: %s", input, err)
+		}
+		defer file.Close()
+		source, name = file, input
+	}
+	lexer, err := piglex.NewLexer(spec, source)
+	if err != nil {
+		return err
+	}
+	lexer.Name = name
+	lexer.Coverage = coverage
+	lexer.IgnoreMacros()
+	for {
+		if _, err := lexer.Next(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,50 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"flag"
+	"os"
+)
+
+var dumpCommand = &command{
+	name:  "dump",
+	short: "print the parsed spec",
+	help: `Dump parses the spec and prints it as piglex understands it: directives,
+definitions, tokens, states, and the rules with their states and actions.
+A spec with errors is dumped as far as it could be parsed.`,
+	run: runDump,
+}
+
+func runDump(flags *flag.FlagSet, args []string) int {
+	options := newSpecFlags(flags)
+	if ok, code := parse(flags, args); !ok {
+		return code
+	}
+
+	spec, err := options.read()
+	if spec != nil {
+		spec.Dump(os.Stdout)
+	}
+	if err != nil {
+		printError(err)
+		return EXIT_ERRORS
+	}
+	return EXIT_OK
+}
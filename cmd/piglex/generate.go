@@ -0,0 +1,154 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/peergum/piglex"
+)
+
+var generateCommand = &command{
+	name:  "generate",
+	short: "generate the lexer (default command)",
+	help: `Generate parses and validates the spec, and writes the Go code of the lexer:
+the definitions only (tokens, states, rules and actions, to be built along with
+the lexer template), or a complete lexer if the spec has an %output directive.`,
+	run: runGenerate,
+}
+
+func runGenerate(flags *flag.FlagSet, args []string) int {
+	options := newSpecFlags(flags)
+	output := flags.String("o", "lexer-defs.go", "Definition file to create (- for stdout)")
+	backend := flags.String("b", "", "Backend: regexp (runtime regexps, default) or dfa (generated tables)")
+	importFile := flags.String("T", "", "Token table (JSON) to number the tokens as the parser does")
+	exportFile := flags.String("E", "", "Token table to write: file.json or file.go")
+	coverage := flags.Bool("coverage", false, "Count the rules fired, the lexer reporting them with Coverage()")
+	if ok, code := parse(flags, args); !ok {
+		return code
+	}
+	fmt.Fprintf(os.Stderr, "Welcome to %s.\n", app)
+
+	spec, code := options.load()
+	if spec == nil {
+		return code
+	}
+	if *importFile != "" {
+		if err := importTokens(spec, *importFile); err != nil {
+			printError(err)
+			return EXIT_ERRORS
+		}
+	}
+	if *exportFile != "" {
+		if err := exportTokens(spec, *exportFile); err != nil {
+			printError(err)
+			return EXIT_ERRORS
+		}
+		fmt.Fprintf(os.Stderr, "Token table written to %s.\n", *exportFile)
+	}
+
+	source, err := piglex.Generate(spec, piglex.Options{
+		Backend:  *backend,
+		Coverage: *coverage,
+	})
+	if err != nil {
+		printError(err)
+		return EXIT_ERRORS
+	}
+	file := outputFile(spec, flags, *output)
+	if file == STDIO {
+		if _, err := os.Stdout.Write(source); err != nil {
+			fmt.Fprintf(os.Stderr, "Can't write output: %s\n", err)
+			return EXIT_ERRORS
+		}
+		return EXIT_OK
+	}
+	if err := ioutil.WriteFile(file, source, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Can't write output file %s: %s\n", file, err)
+		return EXIT_ERRORS
+	}
+	fmt.Fprintf(os.Stderr, "Output written to %s.\n", file)
+	return EXIT_OK
+}
+
+//
+// outputFile decides where to write: the %output file of the spec,
+// relative to it, or the -o file. The -o flag always overrides the
+// %output directive. A spec read from stdin has its %output relative
+// to the current directory.
+//
+func outputFile(spec *piglex.Spec, flags *flag.FlagSet, output string) string {
+	if spec.Output == "" || flagSet(flags, "o") {
+		return output
+	}
+	return filepath.Join(filepath.Dir(spec.File), spec.Output)
+}
+
+//
+// importTokens numbers the tokens of the spec from a JSON token table
+//
+func importTokens(spec *piglex.Spec, file string) error {
+	source, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	table, err := piglex.ReadTokenTable(source)
+	if err != nil {
+		return err
+	}
+	return spec.ImportTokens(table)
+}
+
+//
+// exportTokens writes the token table of the spec, as a Go file
+// if the name ends in .go, in JSON otherwise
+//
+func exportTokens(spec *piglex.Spec, file string) error {
+	if filepath.Ext(file) == ".go" {
+		source, err := piglex.GenerateTokens(spec)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(file, source, 0644)
+	}
+	output, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err := spec.TokenTable().WriteJSON(output); err != nil {
+		output.Close()
+		return err
+	}
+	return output.Close()
+}
+
+func flagSet(flags *flag.FlagSet, name string) bool {
+	found := false
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			found = true
+		}
+	})
+	return found
+}
@@ -0,0 +1,261 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/peergum/piglex"
+)
+
+// exit codes
+const (
+	EXIT_OK     = 0
+	EXIT_ERRORS = 1 // errors in the spec, or while reading or writing files
+	EXIT_USAGE  = 2 // bad command line
+)
+
+// name of the standard input and output, for -l, -f and -o
+const STDIO = "-"
+
+var (
+	app string = filepath.Base(os.Args[0])
+)
+
+//
+// command is a piglex subcommand. run defines the flags of the
+// command, parses its arguments and returns the exit code.
+//
+type command struct {
+	name  string
+	short string // one line description
+	help  string // details, in the help of the command
+	run   func(flags *flag.FlagSet, args []string) int
+}
+
+var commands = []*command{
+	generateCommand,
+	checkCommand,
+	dumpCommand,
+	tokenizeCommand,
+	testCommand,
+	coverageCommand,
+}
+
+//
+// specFlags are the flags of the commands reading a spec
+//
+type specFlags struct {
+	lex      *string
+	debug    *bool
+	caseless *bool
+	include  pathList
+	checks   piglex.Checks
+}
+
+//
+// pathList is a repeatable command line flag (-I dir -I dir...)
+//
+type pathList []string
+
+func (list *pathList) String() string {
+	return strings.Join(*list, string(os.PathListSeparator))
+}
+
+func (list *pathList) Set(value string) error {
+	*list = append(*list, filepath.SplitList(value)...)
+	return nil
+}
+
+//
+// main runs the command given as first argument, generate if the
+// arguments start with a flag
+//
+func main() {
+	args := os.Args[1:]
+	if len(args) > 0 {
+		switch args[0] {
+		case "-v", "-version", "version":
+			showVersion()
+		case "-h", "-help", "--help", "help":
+			os.Exit(help(args[1:]))
+		}
+	}
+	cmd := generateCommand
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd = findCommand(args[0])
+		if cmd == nil {
+			fmt.Fprintf(os.Stderr, "%s: unknown command %q\n", app, args[0])
+			usage()
+			os.Exit(EXIT_USAGE)
+		}
+		args = args[1:]
+	}
+	os.Exit(cmd.run(cmd.flagSet(), args))
+}
+
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+//
+// flagSet returns an empty flag set for the command, printing
+// its help as usage
+//
+func (cmd *command) flagSet() *flag.FlagSet {
+	flags := flag.NewFlagSet(app+" "+cmd.name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s %s [flags]\n\n%s\n\nFlags:\n", app, cmd.name, cmd.help)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+//
+// parse parses the arguments of a command, which takes none
+// but its flags. It returns false, and the exit code, on errors.
+//
+func parse(flags *flag.FlagSet, args []string) (bool, int) {
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return false, EXIT_OK
+		}
+		return false, EXIT_USAGE
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "%s: unexpected argument %q\n", flags.Name(), flags.Arg(0))
+		flags.Usage()
+		return false, EXIT_USAGE
+	}
+	return true, EXIT_OK
+}
+
+//
+// help prints the help of a command, or the list of commands
+//
+func help(args []string) int {
+	if len(args) == 0 {
+		usage()
+		return EXIT_OK
+	}
+	cmd := findCommand(args[0])
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "%s: unknown command %q\n", app, args[0])
+		usage()
+		return EXIT_USAGE
+	}
+	// the flags are defined by run, which prints the help for -h
+	return cmd.run(cmd.flagSet(), []string{"-h"})
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s [command] [flags]\n\nCommands:\n", app)
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.short)
+	}
+	fmt.Fprintf(os.Stderr, "\nWithout a command, %s generates code. Run %s help <command> for the flags of a command.\n", app, app)
+}
+
+func newSpecFlags(flags *flag.FlagSet) *specFlags {
+	options := &specFlags{
+		lex:      flags.String("l", "lex.pigl", "File with PigLex rules (- for stdin)"),
+		debug:    flags.Bool("d", false, "Debug Mode"),
+		caseless: flags.Bool("i", false, "Case-insensitive lexer"),
+		checks:   piglex.DefaultChecks(),
+	}
+	flags.Var(&options.include, "I", "Directory to search for included files (repeatable)")
+	flags.Var(options.checks, "W", "Severity of checks, as check=error|warning|ignore[,...]")
+	return options
+}
+
+//
+// load parses and validates the spec, printing the diagnostics.
+// It returns a nil spec, and the exit code, on errors.
+//
+func (options *specFlags) load() (*piglex.Spec, int) {
+	spec, err := options.read()
+	if err != nil {
+		printError(err)
+		return nil, EXIT_ERRORS
+	}
+	if *options.debug {
+		spec.Dump(os.Stderr)
+	}
+	if diags := piglex.Validate(spec, options.checks); len(diags) > 0 {
+		printError(diags)
+		if diags.Errors() > 0 {
+			return nil, EXIT_ERRORS
+		}
+	}
+	return spec, EXIT_OK
+}
+
+//
+// read parses the spec from the -l file, or stdin. Errors in the
+// spec are returned along with the (partial) spec.
+//
+func (options *specFlags) read() (*piglex.Spec, error) {
+	piglex.Debug = *options.debug
+	if *options.lex == "" {
+		return nil, fmt.Errorf("no PigLex file informed")
+	}
+	var lexFile io.Reader = os.Stdin
+	name := "<stdin>"
+	if *options.lex != STDIO {
+		file, err := os.Open(*options.lex)
+		if err != nil {
+			return nil, fmt.Errorf("can't open PigLex file %s: %s", *options.lex, err)
+		}
+		defer file.Close()
+		lexFile, name = file, *options.lex
+	}
+	return piglex.ParseReader(lexFile, &piglex.ParseOptions{
+		Name:     name,
+		Paths:    options.include,
+		Caseless: *options.caseless,
+	})
+}
+
+//
+// printError prints an error, or each diagnostic of a list with
+// its source excerpt
+//
+func printError(err error) {
+	if list, ok := err.(piglex.ErrorList); ok {
+		list.Print(os.Stderr)
+		fmt.Fprintf(os.Stderr, "%d error(s), %d warning(s)\n", list.Errors(), len(list)-list.Errors())
+		return
+	}
+	fmt.Fprintln(os.Stderr, "Error:", err)
+}
+
+func showVersion() {
+	fmt.Printf("%s, version %s\n", app, piglex.VERSION)
+	os.Exit(EXIT_OK)
+}
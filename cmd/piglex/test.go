@@ -0,0 +1,85 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/peergum/piglex/piglextest"
+)
+
+var testCommand = &command{
+	name:  "test",
+	short: "check the spec against golden token files",
+	help: `Test runs the spec on each input file of the directories given (testdata by
+default), or on the input files given, and compares the tokens found with
+those of the golden file next to the input (input.tokens), as printed by
+piglex tokenize. With -update, the golden files are written instead. It exits
+with 1 if any input doesn't give the tokens expected.
+
+    piglex test -l sample.pigl testdata`,
+	run: runTest,
+}
+
+func runTest(flags *flag.FlagSet, args []string) int {
+	options := newSpecFlags(flags)
+	update := flags.Bool("update", false, "Write the golden files from the tokens found")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return EXIT_OK
+		}
+		return EXIT_USAGE
+	}
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"testdata"}
+	}
+
+	spec, code := options.load()
+	if spec == nil {
+		return code
+	}
+	results, err := piglextest.Check(spec, paths, *update)
+	if err != nil {
+		printError(err)
+		return EXIT_ERRORS
+	}
+	failed := 0
+	for _, result := range results {
+		switch {
+		case result.Err != nil:
+			fmt.Printf("FAIL %s: %s\n", result.Input, result.Err)
+		case result.Diff != "":
+			fmt.Printf("FAIL %s\n%s", result.Input, result.Diff)
+		case result.Updated:
+			fmt.Printf("updated %s\n", result.Golden)
+			continue
+		default:
+			fmt.Printf("ok   %s\n", result.Input)
+			continue
+		}
+		failed++
+	}
+	if failed > 0 {
+		fmt.Printf("%d of %d input(s) failed\n", failed, len(results))
+		return EXIT_ERRORS
+	}
+	return EXIT_OK
+}
@@ -0,0 +1,120 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/peergum/piglex"
+)
+
+var tokenizeCommand = &command{
+	name:  "tokenize",
+	short: "run the spec on an input and print the tokens",
+	help: `Tokenize runs the rules of the spec on an input (-f), without generating
+any code, and prints the tokens found, one per line:
+
+    line:col TOKEN_NAME "text" [state]
+
+or as JSON Lines with -json. Macro actions are not run. It stops at the first
+error, exiting with 1.`,
+	run: runTokenize,
+}
+
+func runTokenize(flags *flag.FlagSet, args []string) int {
+	options := newSpecFlags(flags)
+	input := flags.String("f", STDIO, "Source file to parse (- for stdin)")
+	jsonLines := flags.Bool("json", false, "Print the tokens as JSON Lines")
+	if ok, code := parse(flags, args); !ok {
+		return code
+	}
+
+	spec, code := options.load()
+	if spec == nil {
+		return code
+	}
+	var source io.Reader = os.Stdin
+	name := "<stdin>"
+	if *input != STDIO {
+		file, err := os.Open(*input)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Can't open source file %s: %s\n", *input, err)
+			return EXIT_ERRORS
+		}
+		defer file.Close()
+		source, name = file, *input
+	}
+	lexer, err := piglex.NewLexer(spec, source)
+	if err != nil {
+		printError(err)
+		return EXIT_ERRORS
+	}
+	lexer.Name = name
+	lexer.IgnoreMacros()
+	output := bufio.NewWriter(os.Stdout)
+	defer output.Flush()
+	encoder := json.NewEncoder(output)
+	for {
+		token, err := lexer.Next()
+		if err == io.EOF {
+			return EXIT_OK
+		}
+		if err != nil {
+			output.Flush()
+			printError(err)
+			return EXIT_ERRORS
+		}
+		if !*jsonLines {
+			token.Dump(output)
+			continue
+		}
+		if err := encoder.Encode(newTokenJSON(token)); err != nil {
+			fmt.Fprintf(os.Stderr, "Can't write output: %s\n", err)
+			return EXIT_ERRORS
+		}
+	}
+}
+
+//
+// tokenJSON is a token in the JSON Lines output
+//
+type tokenJSON struct {
+	Line  int    `json:"line"`
+	Col   int    `json:"col"`
+	ID    int    `json:"id"`
+	Token string `json:"token"`
+	Text  string `json:"text"`
+	State string `json:"state"`
+}
+
+func newTokenJSON(token piglex.Token) *tokenJSON {
+	return &tokenJSON{
+		Line:  token.Pos.Line,
+		Col:   token.Pos.Col,
+		ID:    token.ID,
+		Token: token.Name,
+		Text:  token.Text,
+		State: token.State,
+	}
+}
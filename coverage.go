@@ -0,0 +1,143 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package piglex
+
+import (
+	"fmt"
+	"io"
+)
+
+//
+// Coverage counts how many times each rule fired in each state, over
+// the inputs of one or more lexers (see Lexer.Coverage)
+//
+type Coverage struct {
+	spec    *Spec
+	hits    map[ruleState]int
+	entered map[string]bool
+}
+
+type ruleState struct {
+	rule  *LexRule
+	state string
+}
+
+//
+// NewCoverage returns an empty coverage of the rules of a spec
+//
+func NewCoverage(spec *Spec) *Coverage {
+	return &Coverage{
+		spec:    spec,
+		hits:    map[ruleState]int{},
+		entered: map[string]bool{},
+	}
+}
+
+func (coverage *Coverage) fired(rule *LexRule, state string) {
+	coverage.hits[ruleState{rule, state}]++
+	coverage.entered[state] = true
+}
+
+//
+// Hits returns the number of times a rule fired in a state
+//
+func (coverage *Coverage) Hits(rule *LexRule, state string) int {
+	return coverage.hits[ruleState{rule, state}]
+}
+
+//
+// Entered tells if the lexer was in a state when a rule fired
+//
+func (coverage *Coverage) Entered(state string) bool {
+	return coverage.entered[state]
+}
+
+//
+// Unfired returns the rules that never fired, in any state, in order
+// of state
+//
+func (coverage *Coverage) Unfired() []*LexRule {
+	seen := map[*LexRule]bool{}
+	for key := range coverage.hits {
+		seen[key.rule] = true
+	}
+	rules := []*LexRule{}
+	for _, decl := range coverage.spec.States {
+		for _, i := range coverage.spec.RulesFor(decl.Name) {
+			if rule := coverage.spec.Rules[i]; !seen[rule] {
+				seen[rule] = true
+				rules = append(rules, rule)
+			}
+		}
+	}
+	return rules
+}
+
+//
+// NotEntered returns the states in which no rule fired
+//
+func (coverage *Coverage) NotEntered() []string {
+	states := []string{}
+	for _, decl := range coverage.spec.States {
+		if !coverage.entered[decl.Name] {
+			states = append(states, decl.Name)
+		}
+	}
+	return states
+}
+
+//
+// count returns the number of rules applying to some state
+//
+func (coverage *Coverage) count() int {
+	seen := map[int]bool{}
+	for _, decl := range coverage.spec.States {
+		for _, i := range coverage.spec.RulesFor(decl.Name) {
+			seen[i] = true
+		}
+	}
+	return len(seen)
+}
+
+//
+// Report writes the hits of each rule per state, then the rules that
+// never fired and the states never entered. Generated lexers built
+// with coverage write the same report.
+//
+func (coverage *Coverage) Report(w io.Writer) {
+	for _, decl := range coverage.spec.States {
+		if coverage.entered[decl.Name] {
+			fmt.Fprintf(w, "%s\n", decl.Name)
+		} else {
+			fmt.Fprintf(w, "%s (never entered)\n", decl.Name)
+		}
+		for _, i := range coverage.spec.RulesFor(decl.Name) {
+			rule := coverage.spec.Rules[i]
+			fmt.Fprintf(w, "  %10d  %s  %s\n", coverage.Hits(rule, decl.Name), rule.Pos, rule.Pattern)
+		}
+	}
+	unfired := coverage.Unfired()
+	fmt.Fprintf(w, "%d of %d rule(s) fired\n", coverage.count()-len(unfired), coverage.count())
+	for _, rule := range unfired {
+		fmt.Fprintf(w, "never fired: %s  %s\n", rule.Pos, rule.Pattern)
+	}
+	for _, state := range coverage.NotEntered() {
+		fmt.Fprintf(w, "never entered: %s\n", state)
+	}
+}
@@ -0,0 +1,181 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package piglex
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+//
+// {NAME} in a regexp refers to a definition, while {n,m} is a
+// repetition. Escaped characters are matched first, so that \{NAME}
+// stays literal.
+//
+var defRef = regexp.MustCompile(`\\.|\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+//
+// define handles %define NAME regexp (or %alias): the regexp is the
+// rest of the line, as is
+//
+func (lex *specLexer) define(directive *Directive, rest string) {
+	if len(directive.Args) == 0 {
+		lex.errorf(directive.Pos, "%%%s: missing name", directive.Name)
+		return
+	}
+	name := directive.Args[0]
+	rest = strings.TrimLeft(rest, BLANKSPACES)
+	rest = rest[strings.Index(rest, name)+len(name):]
+	trimmed := strings.TrimLeft(rest, BLANKSPACES)
+	pattern := strings.TrimRight(trimmed, BLANKSPACES)
+	if pattern == "" {
+		lex.errorf(directive.ArgPos(0), "%%%s %s: missing regexp", directive.Name, name)
+		return
+	}
+	regexpPos := directive.ArgPos(0)
+	regexpPos.Col += utf8.RuneCountInString(name) + utf8.RuneCountInString(rest) - utf8.RuneCountInString(trimmed)
+	lex.spec.Defs = append(lex.spec.Defs, &Definition{
+		Name:      name,
+		Regexp:    pattern,
+		Pos:       directive.ArgPos(0),
+		regexpPos: regexpPos,
+	})
+	logMsg("Definition:", name, pattern)
+}
+
+//
+// def returns the first definition of a name, if any
+//
+func (spec *Spec) def(name string) *Definition {
+	for _, def := range spec.Defs {
+		if def.Name == name {
+			return def
+		}
+	}
+	return nil
+}
+
+//
+// defNames returns the names of the definitions used in a regexp
+//
+func defNames(pattern string) []string {
+	names := []string{}
+	for _, match := range defRef.FindAllStringSubmatch(pattern, -1) {
+		if match[1] != "" {
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+//
+// defExpander expands the definitions, each one once
+//
+type defExpander struct {
+	spec     *Spec
+	errors   *ErrorList
+	expanded map[string]string
+	failed   map[string]bool
+	stack    []string
+}
+
+//
+// expandDefs sets the pattern of each rule: its regexp, with the
+// definitions replaced by their (expanded) regexp, as a group.
+// Caseless specs get a (?i) flag, that rules can clear with (?-i).
+//
+func (spec *Spec) expandDefs(errors *ErrorList) {
+	e := &defExpander{
+		spec:     spec,
+		errors:   errors,
+		expanded: map[string]string{},
+		failed:   map[string]bool{},
+	}
+	for _, def := range spec.Defs {
+		e.def(def)
+	}
+	for _, rule := range spec.Rules {
+		rule.Pattern, _ = e.expand(rule.Regexp, rule.Pos)
+		if spec.Caseless {
+			rule.Pattern = "(?i)" + rule.Pattern
+		}
+	}
+}
+
+//
+// expand replaces the definitions used in a pattern starting at pos.
+// It returns false if one of them is undefined or can't be expanded.
+//
+func (e *defExpander) expand(pattern string, pos Pos) (string, bool) {
+	ok := true
+	result := ""
+	last := 0
+	for _, loc := range defRef.FindAllStringSubmatchIndex(pattern, -1) {
+		if loc[2] < 0 {
+			continue
+		}
+		name := pattern[loc[2]:loc[3]]
+		def := e.spec.def(name)
+		if def == nil {
+			refPos := pos
+			refPos.Col += utf8.RuneCountInString(pattern[:loc[0]])
+			e.errors.Add(refPos, SEVERITY_ERROR, "undefined definition {%s}", name)
+			ok = false
+			continue
+		}
+		expanded, defOk := e.def(def)
+		if !defOk {
+			ok = false
+			continue
+		}
+		result += pattern[last:loc[0]] + "(?:" + expanded + ")"
+		last = loc[1]
+	}
+	return result + pattern[last:], ok
+}
+
+//
+// def expands a definition, reporting recursive definitions
+//
+func (e *defExpander) def(def *Definition) (string, bool) {
+	if expanded, ok := e.expanded[def.Name]; ok {
+		return expanded, true
+	}
+	if e.failed[def.Name] {
+		return "", false
+	}
+	for i, name := range e.stack {
+		if name == def.Name {
+			cycle := append(append([]string{}, e.stack[i:]...), def.Name)
+			e.errors.Add(def.Pos, SEVERITY_ERROR, "recursive definition: %s", strings.Join(cycle, " -> "))
+			e.failed[def.Name] = true
+			return "", false
+		}
+	}
+	e.stack = append(e.stack, def.Name)
+	expanded, ok := e.expand(def.Regexp, def.regexpPos)
+	e.stack = e.stack[:len(e.stack)-1]
+	if !ok {
+		e.failed[def.Name] = true
+		return "", false
+	}
+	e.expanded[def.Name] = expanded
+	return expanded, true
+}
@@ -0,0 +1,113 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package piglex
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDefineComments(t *testing.T) {
+	source := `%token C, P, X
+%define HEX [0-9a-f] 
+%define COLOR #{HEX}+
+%define PATH [a-z/]+
+%alias CMT //.*
+%state S # the directive ends here
+%state T // and here
+%lex
+{COLOR}	return C
+{PATH}	return P
+{CMT}	return X
+`
+	spec, err := ParseReader(strings.NewReader(source), &ParseOptions{Name: "t.pigl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defs := []string{}
+	for _, def := range spec.Defs {
+		defs = append(defs, def.Name+" "+def.Regexp)
+	}
+	if got, want := strings.Join(defs, ", "), "HEX [0-9a-f], COLOR #{HEX}+, PATH [a-z/]+, CMT //.*"; got != want {
+		t.Errorf("definitions %q, want %q", got, want)
+	}
+	if spec.state("S") == nil || spec.state("T") == nil {
+		t.Errorf("states S and T not declared: %v", spec.States)
+	}
+	patterns := []string{}
+	for _, rule := range spec.Rules {
+		patterns = append(patterns, rule.Pattern)
+	}
+	if got, want := strings.Join(patterns, " "), "(?:#(?:[0-9a-f])+) (?:[a-z/]+) (?://.*)"; got != want {
+		t.Errorf("patterns %q, want %q", got, want)
+	}
+}
+
+func TestExpandDefs(t *testing.T) {
+	tests := []struct {
+		source   string
+		patterns []string
+		errors   []string
+	}{
+		{
+			"%define D [0-9]\n%define N {D}+(\\.{D}+)?\n%lex\n-?{N}\n{D}{2,3}\n\\{D}x\n",
+			[]string{`-?(?:(?:[0-9])+(\.(?:[0-9])+)?)`, `(?:[0-9]){2,3}`, `\{D}x`},
+			nil,
+		},
+		{
+			"%define D [0-9]\n%lex\nx{NOPE}{D}\n{D}\n",
+			[]string{`x{NOPE}(?:[0-9])`, `(?:[0-9])`},
+			[]string{"t.pigl:3:2: undefined definition {NOPE}"},
+		},
+		{
+			"%define W a{UNDEF}\n%lex\n{W}b\n",
+			[]string{`{W}b`},
+			[]string{"t.pigl:1:12: undefined definition {UNDEF}"},
+		},
+		{
+			"%define X {Y}a\n%define Y {X}b\n%define Z {Z}\n%lex\n{X}\n{Y}\n{Z}\n",
+			[]string{`{X}`, `{Y}`, `{Z}`},
+			[]string{
+				"t.pigl:1:9: recursive definition: X -> Y -> X",
+				"t.pigl:3:9: recursive definition: Z -> Z",
+			},
+		},
+	}
+	for _, test := range tests {
+		spec, err := ParseReader(strings.NewReader(test.source), &ParseOptions{Name: "t.pigl"})
+		errors := []string{}
+		if list, ok := err.(ErrorList); ok {
+			for _, diag := range list {
+				errors = append(errors, diag.Error())
+			}
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(errors, "\n") != strings.Join(test.errors, "\n") {
+			t.Errorf("%q: errors %q, want %q", test.source, errors, test.errors)
+		}
+		patterns := []string{}
+		for _, rule := range spec.Rules {
+			patterns = append(patterns, rule.Pattern)
+		}
+		if strings.Join(patterns, "\n") != strings.Join(test.patterns, "\n") {
+			t.Errorf("%q: patterns %q, want %q", test.source, patterns, test.patterns)
+		}
+	}
+}
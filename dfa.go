@@ -0,0 +1,454 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package piglex
+
+import (
+	"bytes"
+	"fmt"
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+//
+// The dfa backend compiles the rules of each state into a minimized
+// DFA at generation time. Runes are first mapped to equivalence
+// classes (as flex -Ce), then each DFA state has a full row of
+// transitions per class (as flex -Cf), identical rows being shared.
+//
+
+type nfaEdge struct {
+	lo, hi rune
+	to     int
+}
+
+type nfaNode struct {
+	eps   []int
+	edges []nfaEdge
+	rule  int
+}
+
+type nfa struct {
+	nodes []*nfaNode
+}
+
+//
+// dfa is a deterministic automaton over rune intervals, state 0
+// being the start state. next[s][i] is -1 when there's no transition.
+//
+type dfa struct {
+	intervals []rune // start of each interval
+	next      [][]int
+	accept    []int
+}
+
+func (n *nfa) add() int {
+	n.nodes = append(n.nodes, &nfaNode{rule: -1})
+	return len(n.nodes) - 1
+}
+
+func (n *nfa) epsilon(from, to int) {
+	n.nodes[from].eps = append(n.nodes[from].eps, to)
+}
+
+func (n *nfa) edge(from, to int, lo, hi rune) {
+	n.nodes[from].edges = append(n.nodes[from].edges, nfaEdge{lo, hi, to})
+}
+
+//
+// compile adds the Thompson construction of re, starting at node
+// from, and returns the end node
+//
+func (n *nfa) compile(re *syntax.Regexp, from int) (int, error) {
+	switch re.Op {
+	case syntax.OpNoMatch:
+		return n.add(), nil
+	case syntax.OpEmptyMatch:
+		return from, nil
+	case syntax.OpLiteral:
+		current := from
+		for _, r := range re.Rune {
+			next := n.add()
+			if re.Flags&syntax.FoldCase != 0 {
+				for _, folded := range foldOrbit(r) {
+					n.edge(current, next, folded, folded)
+				}
+			} else {
+				n.edge(current, next, r, r)
+			}
+			current = next
+		}
+		return current, nil
+	case syntax.OpCharClass:
+		next := n.add()
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			n.edge(from, next, re.Rune[i], re.Rune[i+1])
+		}
+		return next, nil
+	case syntax.OpAnyCharNotNL:
+		next := n.add()
+		n.edge(from, next, 0, '\n'-1)
+		n.edge(from, next, '\n'+1, unicode.MaxRune)
+		return next, nil
+	case syntax.OpAnyChar:
+		next := n.add()
+		n.edge(from, next, 0, unicode.MaxRune)
+		return next, nil
+	case syntax.OpCapture:
+		return n.compile(re.Sub[0], from)
+	case syntax.OpConcat:
+		current := from
+		for _, sub := range re.Sub {
+			end, err := n.compile(sub, current)
+			if err != nil {
+				return 0, err
+			}
+			current = end
+		}
+		return current, nil
+	case syntax.OpAlternate:
+		end := n.add()
+		for _, sub := range re.Sub {
+			start := n.add()
+			n.epsilon(from, start)
+			subEnd, err := n.compile(sub, start)
+			if err != nil {
+				return 0, err
+			}
+			n.epsilon(subEnd, end)
+		}
+		return end, nil
+	case syntax.OpStar:
+		loop := n.add()
+		n.epsilon(from, loop)
+		body := n.add()
+		n.epsilon(loop, body)
+		end, err := n.compile(re.Sub[0], body)
+		if err != nil {
+			return 0, err
+		}
+		n.epsilon(end, loop)
+		return loop, nil
+	case syntax.OpPlus:
+		start := n.add()
+		n.epsilon(from, start)
+		end, err := n.compile(re.Sub[0], start)
+		if err != nil {
+			return 0, err
+		}
+		n.epsilon(end, start)
+		return end, nil
+	case syntax.OpQuest:
+		start := n.add()
+		n.epsilon(from, start)
+		end, err := n.compile(re.Sub[0], start)
+		if err != nil {
+			return 0, err
+		}
+		n.epsilon(start, end)
+		return end, nil
+	case syntax.OpRepeat:
+		// Simplify() expands repetitions, this is just in case
+		return n.compile(re.Simplify(), from)
+	}
+	return 0, fmt.Errorf("%s is not supported by the dfa backend", re)
+}
+
+func foldOrbit(r rune) []rune {
+	orbit := []rune{r}
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		orbit = append(orbit, f)
+	}
+	return orbit
+}
+
+//
+// newDFA builds the minimized DFA recognizing a list of regexps,
+// a match of regexps[i] accepting rule i. The lowest rule wins when
+// several accept the same input.
+//
+func newDFA(regexps []string) (*dfa, error) {
+	n, start, err := newNFA(regexps)
+	if err != nil {
+		return nil, err
+	}
+	return n.determinize(start).minimize(), nil
+}
+
+//
+// newNFA builds the NFA of a list of regexps, returning its start node
+//
+func newNFA(regexps []string) (*nfa, int, error) {
+	n := &nfa{}
+	start := n.add()
+	for i, pattern := range regexps {
+		re, err := syntax.Parse(pattern, syntax.Perl)
+		if err != nil {
+			return nil, 0, fmt.Errorf("rule %q: %s", pattern, err)
+		}
+		ruleStart := n.add()
+		n.epsilon(start, ruleStart)
+		end, err := n.compile(re.Simplify(), ruleStart)
+		if err != nil {
+			return nil, 0, fmt.Errorf("rule %q: %s", pattern, err)
+		}
+		accept := n.add()
+		n.epsilon(end, accept)
+		n.nodes[accept].rule = i
+	}
+	return n, start, nil
+}
+
+//
+// intervals splits the runes into intervals on which all the
+// edges of the NFA agree
+//
+func (n *nfa) intervals() []rune {
+	bounds := map[rune]bool{0: true}
+	for _, node := range n.nodes {
+		for _, edge := range node.edges {
+			bounds[edge.lo] = true
+			if edge.hi < unicode.MaxRune {
+				bounds[edge.hi+1] = true
+			}
+		}
+	}
+	list := make([]rune, 0, len(bounds))
+	for r := range bounds {
+		list = append(list, r)
+	}
+	sort.Sort(runeList(list))
+	return list
+}
+
+func (n *nfa) closure(set []int) []int {
+	seen := map[int]bool{}
+	stack := append([]int{}, set...)
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[node] {
+			continue
+		}
+		seen[node] = true
+		stack = append(stack, n.nodes[node].eps...)
+	}
+	result := make([]int, 0, len(seen))
+	for node := range seen {
+		result = append(result, node)
+	}
+	sort.Ints(result)
+	return result
+}
+
+//
+// determinize runs the subset construction
+//
+func (n *nfa) determinize(start int) *dfa {
+	d := &dfa{
+		intervals: n.intervals(),
+	}
+	ids := map[string]int{}
+	sets := [][]int{}
+	addSet := func(set []int) int {
+		key := fmt.Sprint(set)
+		if id, ok := ids[key]; ok {
+			return id
+		}
+		id := len(sets)
+		ids[key] = id
+		sets = append(sets, set)
+		accept := -1
+		for _, node := range set {
+			if rule := n.nodes[node].rule; rule >= 0 && (accept < 0 || rule < accept) {
+				accept = rule
+			}
+		}
+		d.accept = append(d.accept, accept)
+		return id
+	}
+	addSet(n.closure([]int{start}))
+	for s := 0; s < len(sets); s++ {
+		targets := make([][]int, len(d.intervals))
+		for _, node := range sets[s] {
+			for _, edge := range n.nodes[node].edges {
+				i := sort.Search(len(d.intervals), func(i int) bool { return d.intervals[i] > edge.lo }) - 1
+				for ; i < len(d.intervals) && d.intervals[i] <= edge.hi; i++ {
+					targets[i] = append(targets[i], edge.to)
+				}
+			}
+		}
+		row := make([]int, len(d.intervals))
+		for i, target := range targets {
+			row[i] = -1
+			if len(target) > 0 {
+				row[i] = addSet(n.closure(target))
+			}
+		}
+		d.next = append(d.next, row)
+	}
+	return d
+}
+
+//
+// minimize merges equivalent states (Moore's partition refinement),
+// keeping the start state first
+//
+func (d *dfa) minimize() *dfa {
+	block := make([]int, len(d.next))
+	for s := range block {
+		block[s] = d.accept[s] + 1
+	}
+	count := 0
+	for {
+		ids := map[string]int{}
+		newBlock := make([]int, len(d.next))
+		for s, row := range d.next {
+			var key bytes.Buffer
+			fmt.Fprintf(&key, "%d:", block[s])
+			for _, next := range row {
+				if next < 0 {
+					key.WriteString("-,")
+				} else {
+					fmt.Fprintf(&key, "%d,", block[next])
+				}
+			}
+			id, ok := ids[key.String()]
+			if !ok {
+				id = len(ids)
+				ids[key.String()] = id
+			}
+			newBlock[s] = id
+		}
+		block = newBlock
+		if len(ids) == count {
+			break
+		}
+		count = len(ids)
+	}
+
+	// blocks are numbered in order of first state, so the start state is block 0
+	min := &dfa{
+		intervals: d.intervals,
+		next:      make([][]int, count),
+		accept:    make([]int, count),
+	}
+	for s, row := range d.next {
+		b := block[s]
+		if min.next[b] != nil {
+			continue
+		}
+		min.accept[b] = d.accept[s]
+		min.next[b] = make([]int, len(row))
+		for i, next := range row {
+			min.next[b][i] = -1
+			if next >= 0 {
+				min.next[b][i] = block[next]
+			}
+		}
+	}
+	return min
+}
+
+//
+// classes groups the intervals having the same transitions in all
+// states into equivalence classes
+//
+func (d *dfa) classes() ([]int, int) {
+	ids := map[string]int{}
+	class := make([]int, len(d.intervals))
+	for i := range d.intervals {
+		column := make([]string, len(d.next))
+		for s, row := range d.next {
+			column[s] = strconv.Itoa(row[i])
+		}
+		key := strings.Join(column, ",")
+		id, ok := ids[key]
+		if !ok {
+			id = len(ids)
+			ids[key] = id
+		}
+		class[i] = id
+	}
+	return class, len(ids)
+}
+
+//
+// writeTable writes the dfaTable literal of a DFA
+//
+func (d *dfa) writeTable(buf *bytes.Buffer) {
+	class, count := d.classes()
+
+	fmt.Fprintf(buf, "{\nclasses: []dfaRange{")
+	for i := 0; i < len(d.intervals); {
+		j := i + 1
+		for j < len(d.intervals) && class[j] == class[i] {
+			j++
+		}
+		hi := rune(unicode.MaxRune)
+		if j < len(d.intervals) {
+			hi = d.intervals[j] - 1
+		}
+		fmt.Fprintf(buf, "{%d, %d, %d}, ", d.intervals[i], hi, class[i])
+		i = j
+	}
+	fmt.Fprintf(buf, "},\nnclasses: %d,\n", count)
+
+	// one row per state and class, identical rows shared
+	rows := map[string]int{}
+	base := make([]int, len(d.next))
+	trans := []int{}
+	for s, row := range d.next {
+		classRow := make([]int, count)
+		for i, next := range row {
+			classRow[class[i]] = next
+		}
+		key := fmt.Sprint(classRow)
+		offset, ok := rows[key]
+		if !ok {
+			offset = len(trans)
+			rows[key] = offset
+			trans = append(trans, classRow...)
+		}
+		base[s] = offset
+	}
+	writeInts(buf, "base", base)
+	writeInts(buf, "trans", trans)
+	writeInts(buf, "accept", d.accept)
+	fmt.Fprintf(buf, "},\n")
+}
+
+func writeInts(buf *bytes.Buffer, name string, values []int) {
+	fmt.Fprintf(buf, "%s: []int32{", name)
+	for i, value := range values {
+		if i%16 == 0 {
+			fmt.Fprintf(buf, "\n")
+		}
+		fmt.Fprintf(buf, "%d, ", value)
+	}
+	fmt.Fprintf(buf, "\n},\n")
+}
+
+type runeList []rune
+
+func (list runeList) Len() int           { return len(list) }
+func (list runeList) Swap(i, j int)      { list[i], list[j] = list[j], list[i] }
+func (list runeList) Less(i, j int) bool { return list[i] < list[j] }
@@ -0,0 +1,142 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package piglex
+
+import (
+	"sort"
+	"testing"
+	"unicode/utf8"
+)
+
+//
+// longest runs a DFA over the input, returning the rule accepting the
+// longest prefix and its length in bytes, -1 and 0 if none
+//
+func (d *dfa) longest(input string) (int, int) {
+	rule, size := -1, 0
+	state := 0
+	for i := 0; i < len(input); {
+		r, n := utf8.DecodeRuneInString(input[i:])
+		interval := sort.Search(len(d.intervals), func(j int) bool { return d.intervals[j] > r }) - 1
+		state = d.next[state][interval]
+		if state < 0 {
+			break
+		}
+		i += n
+		if d.accept[state] >= 0 {
+			rule, size = d.accept[state], i
+		}
+	}
+	return rule, size
+}
+
+func TestDFAMatch(t *testing.T) {
+	d, err := newDFA([]string{
+		`[ \t]+`,
+		`if`,
+		`[a-z]+`,
+		`[0-9]+(\.[0-9]+)?`,
+		`"[^"]*"`,
+		`(?i)end`,
+		`é+`,
+		`X{2,3}y?`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		input string
+		rule  int
+		size  int
+	}{
+		{" \t x", 0, 3},
+		{"if x", 1, 2},
+		{"ifx", 2, 3},
+		{"3.14z", 3, 4},
+		{"3.", 3, 1},
+		{`"a b"c`, 4, 5},
+		{`"open`, -1, 0},
+		{"EnD", 5, 3},
+		{"endx", 2, 4},
+		{"ééa", 6, 4},
+		{"xxxxy", 2, 5},
+		{"XXXXy", 7, 3},
+		{"XXy", 7, 3},
+		{"X", -1, 0},
+		{"!", -1, 0},
+		{"", -1, 0},
+	}
+	for _, test := range tests {
+		rule, size := d.longest(test.input)
+		if rule != test.rule || size != test.size {
+			t.Errorf("%q: got rule %d size %d, want rule %d size %d", test.input, rule, size, test.rule, test.size)
+		}
+	}
+}
+
+func TestDFAMinimize(t *testing.T) {
+	tests := []struct {
+		regexps []string
+		states  int
+	}{
+		{[]string{`(a|b)*abb`}, 4},
+		{[]string{`[a-c]x|[d-f]x`}, 3},
+		{[]string{`a+`, `a*`}, 2},
+		{[]string{`abc`, `abc`}, 4},
+		{[]string{`ab|cb`, `b`}, 4},
+	}
+	for _, test := range tests {
+		d, err := newDFA(test.regexps)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(d.next) != test.states {
+			t.Errorf("%q: %d states, want %d", test.regexps, len(d.next), test.states)
+		}
+	}
+
+	// minimizing keeps the language and the rule accepting each input
+	regexps := []string{`(a|b)*abb`, `[c-d]x|[e-f]x`, `b+a?`}
+	d, err := newDFA(regexps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, start, err := newNFA(regexps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full := n.determinize(start)
+	if len(full.next) <= len(d.next) {
+		t.Errorf("%q: %d states before minimizing, %d after", regexps, len(full.next), len(d.next))
+	}
+	for _, input := range []string{"abb", "aabb", "babb", "a", "b", "bba", "ab", "abab", "cx", "fx", "c", "g"} {
+		wantRule, wantSize := full.longest(input)
+		if rule, size := d.longest(input); rule != wantRule || size != wantSize {
+			t.Errorf("%q: minimized DFA gives rule %d size %d, want rule %d size %d", input, rule, size, wantRule, wantSize)
+		}
+	}
+}
+
+func TestDFAErrors(t *testing.T) {
+	for _, pattern := range []string{`[a-`, `^a`, `a$`, `\bword`} {
+		if _, err := newDFA([]string{`x`, pattern}); err == nil {
+			t.Errorf("%q: no error", pattern)
+		}
+	}
+}
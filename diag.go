@@ -0,0 +1,189 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package piglex
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	SEVERITY_ERROR = iota
+	SEVERITY_WARNING
+	SEVERITY_IGNORE
+)
+
+//
+// Pos is a position in a .pigl file (lines and columns start at 1)
+//
+type Pos struct {
+	File string
+	Line int
+	Col  int
+}
+
+func (pos Pos) String() string {
+	if pos.Line == 0 {
+		return pos.File
+	}
+	return fmt.Sprintf("%s:%d:%d", pos.File, pos.Line, pos.Col)
+}
+
+//
+// Diagnostic is an error or a warning about the spec
+//
+type Diagnostic struct {
+	Pos      Pos
+	Severity int
+	Msg      string
+	Line     string // source line, for the excerpt
+}
+
+func (diag *Diagnostic) Error() string {
+	if diag.Severity == SEVERITY_WARNING {
+		return fmt.Sprintf("%s: warning: %s", diag.Pos, diag.Msg)
+	}
+	return fmt.Sprintf("%s: %s", diag.Pos, diag.Msg)
+}
+
+//
+// Excerpt returns the source line and a caret under the column
+//
+func (diag *Diagnostic) Excerpt() string {
+	if diag.Line == "" || diag.Pos.Col == 0 {
+		return ""
+	}
+	caret := ""
+	for i, c := range []rune(diag.Line) {
+		if i >= diag.Pos.Col-1 {
+			break
+		}
+		if c == '\t' {
+			caret += "\t"
+		} else {
+			caret += " "
+		}
+	}
+	return diag.Line + "\n" + caret + "^"
+}
+
+//
+// ErrorList collects the diagnostics of a run
+//
+type ErrorList []*Diagnostic
+
+func (list ErrorList) Error() string {
+	msgs := make([]string, len(list))
+	for i, diag := range list {
+		msgs[i] = diag.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+//
+// Add appends a diagnostic to the list
+//
+func (list *ErrorList) Add(pos Pos, severity int, format string, v ...interface{}) {
+	*list = append(*list, &Diagnostic{
+		Pos:      pos,
+		Severity: severity,
+		Msg:      fmt.Sprintf(format, v...),
+	})
+}
+
+//
+// Errors returns the number of diagnostics that are errors
+//
+func (list ErrorList) Errors() int {
+	count := 0
+	for _, diag := range list {
+		if diag.Severity == SEVERITY_ERROR {
+			count++
+		}
+	}
+	return count
+}
+
+//
+// Err returns the list as an error if it holds any error, nil otherwise
+//
+func (list ErrorList) Err() error {
+	if list.Errors() == 0 {
+		return nil
+	}
+	return list
+}
+
+//
+// Sort orders the diagnostics by position, files being kept
+// in order of first appearance
+//
+func (list ErrorList) Sort() {
+	files := map[string]int{}
+	for _, diag := range list {
+		if _, ok := files[diag.Pos.File]; !ok {
+			files[diag.Pos.File] = len(files)
+		}
+	}
+	sort.Stable(byPos{list, files})
+}
+
+type byPos struct {
+	list  ErrorList
+	files map[string]int
+}
+
+func (s byPos) Len() int      { return len(s.list) }
+func (s byPos) Swap(i, j int) { s.list[i], s.list[j] = s.list[j], s.list[i] }
+func (s byPos) Less(i, j int) bool {
+	a, b := s.list[i].Pos, s.list[j].Pos
+	if a.File != b.File {
+		return s.files[a.File] < s.files[b.File]
+	}
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+	return a.Col < b.Col
+}
+
+//
+// setExcerpts fills the source lines of the diagnostics
+//
+func (list ErrorList) setExcerpts(sources map[string][]string) {
+	for _, diag := range list {
+		lines := sources[diag.Pos.File]
+		if diag.Pos.Line > 0 && diag.Pos.Line <= len(lines) {
+			diag.Line = lines[diag.Pos.Line-1]
+		}
+	}
+}
+
+//
+// Print writes all the diagnostics, with their excerpt
+//
+func (list ErrorList) Print(w io.Writer) {
+	for _, diag := range list {
+		fmt.Fprintln(w, diag.Error())
+		if excerpt := diag.Excerpt(); excerpt != "" {
+			fmt.Fprintln(w, excerpt)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package piglex
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExcerpt(t *testing.T) {
+	tests := []struct {
+		line string
+		col  int
+		want string
+	}{
+		{"%foo bar", 1, "%foo bar\n^"},
+		{"%token A, b", 11, "%token A, b\n          ^"},
+		{"[a-z]+\t\treturn WORD", 9, "[a-z]+\t\treturn WORD\n      \t\t^"},
+		{"\"é\"\treturn X", 5, "\"é\"\treturn X\n   \t^"},
+		{"", 3, ""},
+		{"abc", 0, ""},
+	}
+	for _, test := range tests {
+		diag := &Diagnostic{Pos: Pos{File: "t.pigl", Line: 1, Col: test.col}, Line: test.line}
+		if got := diag.Excerpt(); got != test.want {
+			t.Errorf("Excerpt(%q, col %d) = %q, want %q", test.line, test.col, got, test.want)
+		}
+	}
+}
+
+func TestDiagnosticExcerpts(t *testing.T) {
+	tests := []struct {
+		spec    string
+		err     string
+		excerpt string
+	}{
+		{
+			"%token NUM\n%foo bar\n%lex\n[0-9]+\treturn NUM\n",
+			"t.pigl:2:1: unknown directive %foo",
+			"%foo bar\n^",
+		},
+		{
+			"%token NUM\n%lex\n[0-9]+\treturn NUM\n[a-z]+\t\treturn WORD\n",
+			"t.pigl:4:9: return: undeclared token WORD [undeclared]",
+			"[a-z]+\t\treturn WORD\n      \t\t^",
+		},
+	}
+	for _, test := range tests {
+		spec, err := ParseReader(strings.NewReader(test.spec), &ParseOptions{Name: "t.pigl"})
+		diags, _ := err.(ErrorList)
+		if err == nil {
+			diags = Validate(spec, nil)
+		}
+		if len(diags) != 1 {
+			t.Errorf("%q: got %d diagnostics (%v), want 1", test.spec, len(diags), err)
+			continue
+		}
+		if got := diags[0].Error(); got != test.err {
+			t.Errorf("%q: got %q, want %q", test.spec, got, test.err)
+		}
+		if got := diags[0].Excerpt(); got != test.excerpt {
+			t.Errorf("%q: excerpt %q, want %q", test.spec, got, test.excerpt)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package piglex
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//go:embed templates/lexer.go
+var lexerTemplate string
+
+// command line of the lexer, for package main only
+//
+//go:embed templates/main.go
+var mainTemplate string
+
+//
+// goSource is a piece of Go code split into its imports and its body
+//
+type goSource struct {
+	name    string
+	imports map[string]string // path -> local name
+	body    string
+}
+
+//
+// generateLexer expands the lexer template with the definitions
+// of the spec into a single Go source file
+//
+func generateLexer(spec *Spec, options *Options) ([]byte, error) {
+	sources := []*goSource{}
+	template, err := splitGoSource("templates/lexer.go", lexerTemplate)
+	if err != nil {
+		return nil, err
+	}
+	sources = append(sources, template)
+	if packageName(spec) == "main" {
+		template, err := splitGoSource("templates/main.go", mainTemplate)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, template)
+	}
+	for _, include := range spec.Includes {
+		source, err := splitGoSource(include.File, include.Code)
+		if err != nil {
+			return nil, fmt.Errorf("include file %q: %s", include.Name, err)
+		}
+		sources = append(sources, source)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by piglex from %s. DO NOT EDIT.\n\n", filepath.Base(spec.File))
+	fmt.Fprintf(&buf, "package %s\n\n", packageName(spec))
+	writeImports(&buf, sources)
+	for _, source := range sources {
+		fmt.Fprintf(&buf, "// --- %s ---\n%s\n", filepath.Base(source.name), source.body)
+	}
+	fmt.Fprintf(&buf, "// --- %s ---\n\n", filepath.Base(spec.File))
+	if err := writeDefs(&buf, spec, options); err != nil {
+		return nil, err
+	}
+
+	return finishSource(buf.Bytes(), spec.Prefix)
+}
+
+//
+// finishSource removes the unused imports of a generated source,
+// renames its top-level identifiers if there's a prefix, and
+// formats it
+//
+func finishSource(src []byte, prefix string) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("generated code is invalid: %s", err)
+	}
+	pruneImports(file)
+	if prefix != "" {
+		renameTopLevel(fset, file, prefix)
+	}
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, file); err != nil {
+		return nil, fmt.Errorf("generated code is invalid: %s", err)
+	}
+	return buf.Bytes(), nil
+}
+
+//
+// pruneImports removes the imports no selector refers to. Imports
+// named _ or ., and those whose name can't be guessed from the path,
+// are kept.
+//
+func pruneImports(file *ast.File) {
+	used := map[string]bool{}
+	ast.Inspect(file, func(node ast.Node) bool {
+		if selector, ok := node.(*ast.SelectorExpr); ok {
+			if ident, ok := selector.X.(*ast.Ident); ok && ident.Obj == nil {
+				used[ident.Name] = true
+			}
+		}
+		return true
+	})
+	imports := []*ast.ImportSpec{}
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT {
+			continue
+		}
+		specs := []ast.Spec{}
+		for _, spec := range gen.Specs {
+			spec := spec.(*ast.ImportSpec)
+			name := path.Base(strings.Trim(spec.Path.Value, `"`))
+			if spec.Name != nil {
+				name = spec.Name.Name
+			}
+			if used[name] || name == "_" || name == "." || !goIdent.MatchString(name) {
+				specs = append(specs, spec)
+				imports = append(imports, spec)
+			}
+		}
+		gen.Specs = specs
+	}
+	file.Imports = imports
+}
+
+//
+// renameTopLevel adds a prefix to the top-level identifiers (but
+// main and init), keeping them exported or not. The file is
+// type-checked so only the identifiers referring to package-level
+// objects are renamed, not struct fields or methods of the same name.
+//
+func renameTopLevel(fset *token.FileSet, file *ast.File, prefix string) {
+	info := &types.Info{
+		Defs: map[*ast.Ident]types.Object{},
+		Uses: map[*ast.Ident]types.Object{},
+	}
+	config := types.Config{
+		Importer: noImporter{},
+		Error:    func(error) {},
+	}
+	pkg, _ := config.Check(file.Name.Name, fset, []*ast.File{file}, info)
+	rename := func(ident *ast.Ident, object types.Object) {
+		if object == nil || object.Parent() != pkg.Scope() {
+			return
+		}
+		if name := object.Name(); name != "main" && name != "init" && name != "_" {
+			ident.Name = prefixName(prefix, name)
+		}
+	}
+	for ident, object := range info.Defs {
+		rename(ident, object)
+	}
+	for ident, object := range info.Uses {
+		rename(ident, object)
+	}
+}
+
+//
+// noImporter fails every import: only the objects declared by the
+// generated file matter to renameTopLevel, and the checker keeps going
+// with a fake package for the others
+//
+type noImporter struct{}
+
+func (noImporter) Import(path string) (*types.Package, error) {
+	return nil, fmt.Errorf("%s not imported", path)
+}
+
+//
+// prefixName prefixes an identifier: Lexer becomes BasicLexer and
+// rules becomes basicRules with the basic prefix
+//
+func prefixName(prefix, name string) string {
+	first, size := utf8.DecodeRuneInString(prefix)
+	if ast.IsExported(name) {
+		return string(unicode.ToUpper(first)) + prefix[size:] + name
+	}
+	nameFirst, nameSize := utf8.DecodeRuneInString(name)
+	return string(unicode.ToLower(first)) + prefix[size:] + string(unicode.ToUpper(nameFirst)) + name[nameSize:]
+}
+
+//
+// splitGoSource separates the imports of a Go source from the rest
+// of its declarations
+//
+func splitGoSource(name, src string) (*goSource, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, name, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	source := &goSource{
+		name:    name,
+		imports: map[string]string{},
+	}
+	bodyStart := fset.Position(file.Name.End()).Offset
+	for _, spec := range file.Imports {
+		localName := ""
+		if spec.Name != nil {
+			localName = spec.Name.Name
+		}
+		source.imports[spec.Path.Value] = localName
+	}
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			bodyStart = fset.Position(gen.End()).Offset
+		}
+	}
+	source.body = src[bodyStart:]
+	return source, nil
+}
+
+//
+// writeImports writes a single import block with the imports
+// of all sources
+//
+func writeImports(buf *bytes.Buffer, sources []*goSource) {
+	imports := map[string]string{}
+	for _, source := range sources {
+		for path, name := range source.imports {
+			imports[path] = name
+		}
+	}
+	paths := make([]string, 0, len(imports))
+	for path := range imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	fmt.Fprintf(buf, "import (\n")
+	for _, path := range paths {
+		fmt.Fprintf(buf, "%s %s\n", imports[path], path)
+	}
+	fmt.Fprintf(buf, ")\n\n")
+}
@@ -0,0 +1,286 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package piglex
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// code generation backends
+const (
+	BACKEND_REGEXP = "regexp"
+	BACKEND_DFA    = "dfa"
+)
+
+//
+// Options drives the code generation
+//
+type Options struct {
+	Backend  string // regexp (default) or dfa, overrides %option backend
+	Coverage bool   // count the rules fired, reported by Lexer.Coverage
+}
+
+//
+// backend returns the backend used for a spec
+//
+func (options *Options) backend(spec *Spec) string {
+	switch {
+	case options.Backend != "":
+		return options.Backend
+	case spec.Backend != "":
+		return spec.Backend
+	}
+	return BACKEND_REGEXP
+}
+
+func packageName(spec *Spec) string {
+	if spec.Package != "" {
+		return spec.Package
+	}
+	return "main"
+}
+
+//
+// generateDefs produces the lexer definitions (tokens, states, rules
+// and actions) for a spec, shaped like templates/lexer-defs.go
+//
+func generateDefs(spec *Spec, options *Options) ([]byte, error) {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "// Code generated by piglex from %s. DO NOT EDIT.\n\n", filepath.Base(spec.File))
+	fmt.Fprintf(&buf, "package %s\n\n", packageName(spec))
+	if err := writeDefs(&buf, spec, options); err != nil {
+		return nil, err
+	}
+
+	source, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated code is invalid: %s", err)
+	}
+	return source, nil
+}
+
+//
+// writeDefs writes the definitions without package clause
+//
+func writeDefs(buf *bytes.Buffer, spec *Spec, options *Options) error {
+	macros, err := findMacros(spec)
+	if err != nil {
+		return err
+	}
+	if err := checkMacros(spec, macros); err != nil {
+		return err
+	}
+	if err := checkPatterns(spec); err != nil {
+		return err
+	}
+
+	if len(spec.Tokens) > 0 {
+		fmt.Fprintf(buf, "const (\n")
+		ids := spec.tokenIDs()
+		for i, decl := range spec.Tokens {
+			switch {
+			case len(spec.TokenIDs) > 0:
+				// numbered as in the imported token table
+				fmt.Fprintf(buf, "%s TokenID = %d\n", tokenConst(decl.Name), ids[decl.Name])
+			case i == 0:
+				fmt.Fprintf(buf, "%s TokenID = 256 + iota\n", tokenConst(decl.Name))
+			default:
+				fmt.Fprintf(buf, "%s\n", tokenConst(decl.Name))
+			}
+		}
+		fmt.Fprintf(buf, ")\n\n")
+	}
+
+	fmt.Fprintf(buf, "const (\n")
+	for i, decl := range spec.States {
+		if i == 0 {
+			fmt.Fprintf(buf, "%s StateID = iota\n", stateConst(decl.Name))
+		} else {
+			fmt.Fprintf(buf, "%s\n", stateConst(decl.Name))
+		}
+	}
+	fmt.Fprintf(buf, ")\n\n")
+
+	// %option values used by the lexer template
+	fmt.Fprintf(buf, "const (\nDEBUG = %t\nWRAP = %t\nUNICODE = %t\nCOVERAGE = %t\n)\n\n", spec.Debug, spec.Wrap, !spec.NoUnicode, options.Coverage)
+
+	fmt.Fprintf(buf, "var (\nrules = map[string][]*Rule{\n")
+	for _, decl := range spec.States {
+		fmt.Fprintf(buf, "%q: {\n", decl.Name)
+		for _, i := range spec.RulesFor(decl.Name) {
+			fmt.Fprintf(buf, "{%s, %s, %q},\n", quoteRegexp(spec.Rules[i].Pattern), actionFunc(i), spec.Rules[i].Pos)
+		}
+		fmt.Fprintf(buf, "},\n")
+	}
+	fmt.Fprintf(buf, "}\n")
+	if err := writeTables(buf, spec, options); err != nil {
+		return err
+	}
+	writeTokenNames(buf, spec)
+	fmt.Fprintf(buf, ")\n")
+
+	for i, rule := range spec.Rules {
+		fmt.Fprintf(buf, "\n// %s\n", rule.Regexp)
+		fmt.Fprintf(buf, "func %s(lexer *Lexer, token *Token) error {\n", actionFunc(i))
+		writeActions(buf, rule, macros)
+		fmt.Fprintf(buf, "}\n")
+	}
+	return nil
+}
+
+//
+// checkPatterns reports the rules whose regexp doesn't compile, which
+// the generated lexer would only find when starting
+//
+func checkPatterns(spec *Spec) error {
+	diags := ErrorList{}
+	for _, rule := range spec.Rules {
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			diags.Add(rule.Pos, SEVERITY_ERROR, "%s", err)
+		}
+	}
+	diags.setExcerpts(spec.sources)
+	return diags.Err()
+}
+
+//
+// writeTables writes the DFA of each state for the dfa backend,
+// an empty map otherwise
+//
+func writeTables(buf *bytes.Buffer, spec *Spec, options *Options) error {
+	fmt.Fprintf(buf, "dfaTables = map[string]*dfaTable{\n")
+	backend := options.backend(spec)
+	if spec.NoUnicode && backend != BACKEND_DFA {
+		return fmt.Errorf("%%option nounicode needs the dfa backend")
+	}
+	switch backend {
+	case BACKEND_DFA:
+		for _, decl := range spec.States {
+			regexps := []string{}
+			for _, i := range spec.RulesFor(decl.Name) {
+				regexps = append(regexps, spec.Rules[i].Pattern)
+			}
+			dfa, err := newDFA(regexps)
+			if err != nil {
+				return fmt.Errorf("state %s: %s", decl.Name, err)
+			}
+			logMsg("State", decl.Name, "DFA states:", len(dfa.next))
+			fmt.Fprintf(buf, "%q: ", decl.Name)
+			dfa.writeTable(buf)
+		}
+	case BACKEND_REGEXP:
+	default:
+		return fmt.Errorf("unknown backend %q", backend)
+	}
+	fmt.Fprintf(buf, "}\n")
+	return nil
+}
+
+//
+// writeTokenNames writes the names of the tokens by id and the
+// reverse map, and the names of the states
+//
+func writeTokenNames(buf *bytes.Buffer, spec *Spec) {
+	tokens := []string{}
+	seen := map[string]bool{}
+	for _, decl := range spec.Tokens {
+		if !seen[decl.Name] {
+			tokens = append(tokens, decl.Name)
+			seen[decl.Name] = true
+		}
+	}
+	fmt.Fprintf(buf, "tokenNames = map[TokenID]string{\n")
+	for _, name := range tokens {
+		fmt.Fprintf(buf, "%s: %q,\n", tokenConst(name), name)
+	}
+	fmt.Fprintf(buf, "}\n")
+	fmt.Fprintf(buf, "TokenByName = map[string]TokenID{\n")
+	for _, name := range tokens {
+		fmt.Fprintf(buf, "%q: %s,\n", name, tokenConst(name))
+	}
+	fmt.Fprintf(buf, "}\n")
+	fmt.Fprintf(buf, "stateNames = []string{\n")
+	for _, decl := range spec.States {
+		fmt.Fprintf(buf, "%q,\n", decl.Name)
+	}
+	fmt.Fprintf(buf, "}\n")
+}
+
+//
+// writeActions translates rule actions into Go statements
+//
+func writeActions(buf *bytes.Buffer, rule *LexRule, macros map[string]*macroFunc) {
+	actions := rule.Actions
+	for i, action := range actions {
+		switch action.Kind {
+		case ACTION_MACRO:
+			writeMacroCall(buf, rule, action, macros[action.Name])
+		case ACTION_STATE:
+			fmt.Fprintf(buf, "lexer.state = %q\n", action.Name)
+		case ACTION_RETURN:
+			if i < len(actions)-1 {
+				logMsg("Actions after return are ignored:", actions[i+1:])
+			}
+			fmt.Fprintf(buf, "return lexer.emit(%s, token)\n", tokenValue(action))
+			return
+		}
+	}
+	fmt.Fprintf(buf, "return nil\n")
+}
+
+func tokenConst(name string) string {
+	return "TOKEN_" + name
+}
+
+//
+// tokenValue is the id returned by an action: the TOKEN_ constant,
+// or the character itself for a literal
+//
+func tokenValue(action *Action) string {
+	if action.Char != 0 {
+		return strconv.QuoteRune(action.Char)
+	}
+	return tokenConst(action.Name)
+}
+
+func stateConst(name string) string {
+	return "STATE_" + strings.TrimPrefix(name, "_")
+}
+
+func actionFunc(rule int) string {
+	return fmt.Sprintf("action_%d", rule)
+}
+
+//
+// quoteRegexp returns the regexp as a Go string literal,
+// raw if possible
+//
+func quoteRegexp(regexp string) string {
+	if strings.IndexRune(regexp, '`') < 0 && strings.IndexRune(regexp, '\n') < 0 {
+		return "`" + regexp + "`"
+	}
+	return strconv.Quote(regexp)
+}
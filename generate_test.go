@@ -0,0 +1,47 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package piglex
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateInvalidPattern(t *testing.T) {
+	source := "%token NUM\n%lex\n[0-9]+\treturn NUM\n[a-\treturn NUM\n"
+	for _, backend := range []string{BACKEND_REGEXP, BACKEND_DFA} {
+		spec, err := ParseReader(strings.NewReader(source), &ParseOptions{Name: "t.pigl"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = Generate(spec, Options{Backend: backend})
+		diags, ok := err.(ErrorList)
+		if !ok || len(diags) != 1 {
+			t.Errorf("%s: got %v, want one diagnostic", backend, err)
+			continue
+		}
+		want := "t.pigl:4:1: error parsing regexp: missing closing ]: `[a-`"
+		if got := diags[0].Error(); got != want {
+			t.Errorf("%s: got %q, want %q", backend, got, want)
+		}
+		if diags[0].Line != "[a-\treturn NUM" {
+			t.Errorf("%s: excerpt line %q", backend, diags[0].Line)
+		}
+	}
+}
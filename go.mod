@@ -0,0 +1,3 @@
+module github.com/peergum/piglex
+
+go 1.16
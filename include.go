@@ -0,0 +1,148 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package piglex
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// extensions of included files parsed as PigLex fragments,
+// anything else is Go code
+var specExtensions = []string{".pigl", ".pigy"}
+
+//
+// include handles an %include directive: PigLex fragments are parsed
+// recursively into the spec, in the scope of the directive, Go code is
+// kept for the generated lexer.
+// Problems with the file are reported as diagnostics at the directive.
+//
+func (lex *specLexer) include(name string, pos Pos) error {
+	file, abs := lex.resolveInclude(name)
+	if file == "" {
+		lex.includeError(pos, "can't find include file %q", name)
+		return nil
+	}
+	for i, parent := range lex.chain {
+		if parent == abs {
+			cycle := append(append([]string{}, lex.chain[i:]...), abs)
+			lex.errorf(pos, "include cycle: %s", strings.Join(cycle, " -> "))
+			return nil
+		}
+	}
+	if !isSpecFile(file) {
+		code, err := ioutil.ReadFile(file)
+		if err != nil {
+			lex.includeError(pos, "can't read include file %q: %s", name, err)
+			return nil
+		}
+		lex.spec.Includes = append(lex.spec.Includes, &Include{
+			Name: name,
+			File: file,
+			Code: string(code),
+			Pos:  pos,
+		})
+		return nil
+	}
+
+	source, err := os.Open(file)
+	if err != nil {
+		lex.includeError(pos, "can't open include file %q: %s", name, err)
+		return nil
+	}
+	defer source.Close()
+	logMsg("Parsing include file:", file)
+
+	child := newSpecLexer(lex.spec, source, file)
+	child.chain = append(append([]string{}, lex.chain...), abs)
+	child.paths = lex.paths
+	child.errors = lex.errors
+	child.scope = lex.scope
+	child.yacc = lex.yacc
+	if lex.inRules() {
+		child.replaceState(&lexState{
+			current: STATE_LEXRULES,
+			token:   &lexToken{value: ""},
+		})
+	}
+	return child.run()
+}
+
+//
+// resolveInclude looks for an include file next to the including file,
+// then in the search paths. It returns the file found and its absolute
+// path, or empty strings.
+//
+func (lex *specLexer) resolveInclude(name string) (string, string) {
+	candidates := []string{name}
+	if !filepath.IsAbs(name) {
+		candidates = []string{filepath.Join(filepath.Dir(lex.file), name)}
+		for _, dir := range lex.paths {
+			candidates = append(candidates, filepath.Join(dir, name))
+		}
+	}
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			if abs, err := filepath.Abs(candidate); err == nil {
+				return candidate, abs
+			}
+		}
+	}
+	return "", ""
+}
+
+//
+// includeError reports an error along with the chain of include files
+//
+func (lex *specLexer) includeError(pos Pos, format string, v ...interface{}) {
+	msg := fmt.Sprintf(format, v...)
+	if len(lex.chain) > 1 {
+		parents := []string{}
+		for i := len(lex.chain) - 2; i >= 0; i-- {
+			parents = append(parents, lex.chain[i])
+		}
+		msg += " (included from " + strings.Join(parents, ", ") + ")"
+	}
+	lex.errorf(pos, "%s", msg)
+}
+
+//
+// inRules tells if the directive being handled is within the rules section
+//
+func (lex *specLexer) inRules() bool {
+	for _, state := range lex.states {
+		if state.current == STATE_LEXRULES {
+			return true
+		}
+	}
+	return false
+}
+
+func isSpecFile(file string) bool {
+	ext := filepath.Ext(file)
+	for _, specExt := range specExtensions {
+		if ext == specExt {
+			return true
+		}
+	}
+	return false
+}
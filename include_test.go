@@ -0,0 +1,160 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package piglex
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+//
+// writeFiles writes the files of a test into a new directory,
+// returned along with its removal
+//
+func writeFiles(t *testing.T, files map[string]string) (string, func()) {
+	dir, err := ioutil.TempDir("", "piglex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func TestIncludeScope(t *testing.T) {
+	dir, remove := writeFiles(t, map[string]string{
+		"main.pigl": "%token A, B, C\n%state _S\n%lex\nc\treturn C\n%only _S\n%include \"frag.pigl\"\nb\treturn B\n",
+		"frag.pigl": "a\treturn A\n",
+	})
+	defer remove()
+	spec, err := ParseFile(filepath.Join(dir, "main.pigl"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		state string
+		rules string
+	}{
+		{INITIAL_STATE, "c"},
+		{"_S", "c a b"},
+	}
+	for _, test := range tests {
+		rules := ""
+		for _, i := range spec.RulesFor(test.state) {
+			if rules != "" {
+				rules += " "
+			}
+			rules += spec.Rules[i].Regexp
+		}
+		if rules != test.rules {
+			t.Errorf("rules of %s: %q, want %q", test.state, rules, test.rules)
+		}
+	}
+}
+
+func TestInclude(t *testing.T) {
+	dir, remove := writeFiles(t, map[string]string{
+		"main.pigl":        "%token A, B\n%include \"rel/frag.pigl\"\n%include \"lib.pigl\"\n%lex\n",
+		"rel/frag.pigl":    "%include \"sibling.pigl\"\n",
+		"rel/sibling.pigl": "%state _REL\n",
+		"lib/lib.pigl":     "%state _LIB\n",
+		"other/lib.pigl":   "%state _OTHER\n",
+	})
+	defer remove()
+	spec, err := ParseFile(filepath.Join(dir, "main.pigl"), &ParseOptions{
+		Paths: []string{filepath.Join(dir, "lib"), filepath.Join(dir, "other")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	states := []string{}
+	for _, decl := range spec.States {
+		states = append(states, decl.Name)
+	}
+	// relative to the including file, then the first of the -I paths
+	if got, want := strings.Join(states, " "), INITIAL_STATE+" _REL _LIB"; got != want {
+		t.Errorf("states %q, want %q", got, want)
+	}
+}
+
+func TestIncludeErrors(t *testing.T) {
+	dir, remove := writeFiles(t, map[string]string{
+		"a.pigl":     "%token A\n%include \"b.pigl\"\n%lex\n",
+		"b.pigl":     "%include \"a.pigl\"\n",
+		"m.pigl":     "%token A\n%include \"sub/n.pigl\"\n%lex\n",
+		"sub/n.pigl": "\n%include \"missing.pigl\"\n",
+	})
+	defer remove()
+	tests := []struct {
+		file string
+		err  string
+	}{
+		{"a.pigl", "b.pigl:1:11: include cycle: a.pigl -> b.pigl -> a.pigl"},
+		{"m.pigl", "sub/n.pigl:2:11: can't find include file \"missing.pigl\" (included from m.pigl)"},
+	}
+	for _, test := range tests {
+		_, err := ParseFile(filepath.Join(dir, test.file), nil)
+		if err == nil {
+			t.Errorf("%s: no error", test.file)
+			continue
+		}
+		got := strings.Replace(err.Error(), dir+string(filepath.Separator), "", -1)
+		if got != test.err {
+			t.Errorf("%s: %q, want %q", test.file, got, test.err)
+		}
+	}
+}
+
+func TestIncludeCode(t *testing.T) {
+	dir, remove := writeFiles(t, map[string]string{
+		"main.pigl": "%token A\n%include \"code.go\"\n%lex\na\treturn A\n",
+		"code.go":   "package other\n\nimport \"strings\"\n\nfunc shout(s string) string {\n\treturn strings.ToUpper(s)\n}\n",
+	})
+	defer remove()
+	spec, err := ParseFile(filepath.Join(dir, "main.pigl"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spec.Includes) != 1 || spec.Includes[0].Name != "code.go" {
+		t.Fatalf("includes %v, want code.go", spec.Includes)
+	}
+	source, err := GenerateLexer(spec, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := string(source)
+	for _, want := range []string{"package main\n", "\t\"strings\"\n", "// --- code.go ---\n", "func shout(s string) string {"} {
+		if !strings.Contains(code, want) {
+			t.Errorf("generated lexer without %q", want)
+		}
+	}
+	if strings.Contains(code, "package other") {
+		t.Errorf("package clause of code.go kept")
+	}
+}
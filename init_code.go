@@ -0,0 +1,16 @@
+//go:build ignore
+// +build ignore
+
+//
+// Sample code included by sample.pigl
+//
+
+package main
+
+import (
+	"strings"
+)
+
+func normalize(value string) string {
+	return strings.ToUpper(value)
+}
@@ -0,0 +1,579 @@
+// Code generated by piglex from coverage.pigl. DO NOT EDIT.
+
+package sample
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// --- lexer.go ---
+
+const (
+	CoverageVERSION = "0.1"
+)
+
+type CoverageRule struct {
+	regexp string
+	action func(*CoverageLexer, *CoverageToken) error
+	pos    string // position of the rule in the .pigl file
+}
+
+// Pos is a position in the input: Offset in bytes from the start,
+// Line and Col (in runes) from 1
+type CoveragePos struct {
+	Offset int
+	Line   int
+	Col    int
+}
+
+// TokenID is the id of a token (TOKEN_ constants), StateID the id
+// of a state (STATE_ constants)
+type CoverageTokenID int
+type CoverageStateID int
+
+// Token is a token found by the lexer, from Pos to EndPos (excluded),
+// in State
+type CoverageToken struct {
+	ID     CoverageTokenID
+	Text   string
+	Pos    CoveragePos
+	EndPos CoveragePos
+	State  string
+}
+
+// matcher finds the rule matching the longest prefix of the input,
+// returning its index in the rules of the state and the match length.
+// When several rules match the same length, the first one wins.
+type coverageMatcher interface {
+	match(in *coverageInput) (int, int)
+}
+
+// regexpMatcher holds all the rules of a state compiled into a single
+// regexp, each rule being a group of the alternation. full holds each
+// rule alone, to break ties.
+type coverageRegexpMatcher struct {
+	regexp *regexp.Regexp
+	groups []int
+	full   []*regexp.Regexp
+}
+
+// dfaTable is a DFA generated by piglex (dfa backend): runes map to
+// classes, base[state]+class is the index of the next state in trans
+type coverageDfaTable struct {
+	classes  []coverageDfaRange
+	nclasses int
+	base     []int32
+	trans    []int32
+	accept   []int32
+	latin    []int32
+}
+
+type coverageDfaRange struct {
+	lo, hi rune
+	class  int32
+}
+
+// input buffers the source from the start of the current token,
+// reading more as the matchers need it
+type coverageInput struct {
+	source io.Reader
+	buffer []byte
+	start  int         // start of the token in buffer
+	pos    CoveragePos // position of the token in the source
+	eof    bool
+	err    error
+}
+
+// runeReader reads the input from the start of the token,
+// for the regexp matchers
+type coverageRuneReader struct {
+	in  *coverageInput
+	pos int
+}
+
+type CoverageLexer struct {
+	Wrap func() io.Reader // next input, at the end of one (WRAP)
+
+	state string
+	in    coverageInput
+	token *CoverageToken   // emitted by the last action
+	hits  map[string][]int // times each rule fired, per state (COVERAGE)
+}
+
+var (
+	coverageMatchers = map[string]coverageMatcher{}
+)
+
+func init() {
+	for state, list := range coverageRules {
+		if table, ok := coverageDfaTables[state]; ok {
+			table.init()
+			coverageMatchers[state] = table
+		} else {
+			coverageMatchers[state] = coverageNewMatcher(list)
+		}
+	}
+}
+
+// newMatcher compiles the rules of a state once, as a leftmost-longest
+// alternation: (rule1)|(rule2)|...
+func coverageNewMatcher(rules []*CoverageRule) *coverageRegexpMatcher {
+	m := &coverageRegexpMatcher{
+		groups: make([]int, len(rules)),
+		full:   make([]*regexp.Regexp, len(rules)),
+	}
+	pattern := ""
+	group := 1
+	for i, rule := range rules {
+		re := regexp.MustCompile(rule.regexp)
+		m.full[i] = regexp.MustCompile("^(?:" + rule.regexp + ")$")
+		if i > 0 {
+			pattern += "|"
+		}
+		pattern += "(" + rule.regexp + ")"
+		m.groups[i] = group
+		group += 1 + re.NumSubexp()
+	}
+	m.regexp = regexp.MustCompile("^(?:" + pattern + ")")
+	m.regexp.Longest()
+	return m
+}
+
+// match returns the rule matching the longest prefix of input, and
+// the length of the match. The regexp reads the input as long as the
+// match can be extended. It tells which rule matched, but not the
+// first of the rules giving the same length: the rules before it are
+// checked against the token.
+func (m *coverageRegexpMatcher) match(in *coverageInput) (int, int) {
+	loc := m.regexp.FindReaderSubmatchIndex(&coverageRuneReader{in: in})
+	if loc == nil || loc[1] == 0 {
+		return -1, 0
+	}
+	token := in.token(loc[1])
+	for i, group := range m.groups {
+		if loc[2*group] >= 0 {
+			return i, loc[1]
+		}
+		if m.full[i].Match(token) {
+			return i, loc[1]
+		}
+	}
+	return -1, 0
+}
+
+// init builds the class lookup table of the first 256 runes
+func (table *coverageDfaTable) init() {
+	latin := make([]int32, 256)
+	for r := range latin {
+		latin[r] = table.class(rune(r))
+	}
+	table.latin = latin
+}
+
+func (table *coverageDfaTable) class(r rune) int32 {
+	if r < rune(len(table.latin)) {
+		return table.latin[r]
+	}
+	i := sort.Search(len(table.classes), func(i int) bool { return table.classes[i].hi >= r })
+	if i < len(table.classes) && table.classes[i].lo <= r {
+		return table.classes[i].class
+	}
+	return -1
+}
+
+// match runs the DFA as long as it can, and backs up to the last
+// accepting state
+func (table *coverageDfaTable) match(in *coverageInput) (int, int) {
+	state := int32(0)
+	rule, size := -1, 0
+	for i := 0; ; {
+		r, n := in.peek(i)
+		if n == 0 {
+			break
+		}
+		if !CoverageUNICODE {
+			// runes are bytes
+			r, n = rune(in.buffer[in.start+i]), 1
+		}
+		class := table.class(r)
+		if class < 0 {
+			break
+		}
+		state = table.trans[table.base[state]+class]
+		if state < 0 {
+			break
+		}
+		i += n
+		if accept := table.accept[state]; accept >= 0 {
+			rule, size = int(accept), i
+		}
+	}
+	return rule, size
+}
+
+// peek decodes the rune at i bytes from the start of the token, reading
+// more of the source if needed. It returns a size of 0 at the end.
+func (in *coverageInput) peek(i int) (rune, int) {
+	for !in.eof && len(in.buffer)-(in.start+i) < utf8.UTFMax {
+		in.fill()
+	}
+	pos := in.start + i
+	if pos >= len(in.buffer) {
+		return 0, 0
+	}
+	if c := in.buffer[pos]; c < utf8.RuneSelf {
+		return rune(c), 1
+	}
+	return utf8.DecodeRune(in.buffer[pos:])
+}
+
+// fill drops what's before the token and reads more of the source
+func (in *coverageInput) fill() {
+	if in.start > 0 {
+		n := copy(in.buffer, in.buffer[in.start:])
+		in.buffer = in.buffer[:n]
+		in.start = 0
+	}
+	if len(in.buffer) == cap(in.buffer) {
+		buffer := make([]byte, len(in.buffer), 2*cap(in.buffer)+4096)
+		copy(buffer, in.buffer)
+		in.buffer = buffer
+	}
+	n, err := in.source.Read(in.buffer[len(in.buffer):cap(in.buffer)])
+	in.buffer = in.buffer[:len(in.buffer)+n]
+	if err != nil {
+		in.eof = true
+		if err != io.EOF {
+			in.err = err
+		}
+	}
+}
+
+// token returns the first size bytes from the start of the token
+func (in *coverageInput) token(size int) []byte {
+	return in.buffer[in.start : in.start+size]
+}
+
+// advance moves the start of the next token, after size bytes
+func (in *coverageInput) advance(size int) {
+	for _, c := range in.token(size) {
+		switch {
+		case c == '\n':
+			in.pos.Line++
+			in.pos.Col = 1
+		case CoverageUNICODE && !utf8.RuneStart(c):
+			// continuation byte of a rune
+		default:
+			in.pos.Col++
+		}
+	}
+	in.start += size
+	in.pos.Offset += size
+}
+
+func (reader *coverageRuneReader) ReadRune() (rune, int, error) {
+	r, n := reader.in.peek(reader.pos)
+	if n == 0 {
+		return 0, 0, io.EOF
+	}
+	reader.pos += n
+	return r, n, nil
+}
+
+func CoverageNewLexer(source io.Reader) *CoverageLexer {
+	lexer := &CoverageLexer{
+		state: "_INIT",
+		in:    coverageNewInput(source),
+	}
+	if CoverageCOVERAGE {
+		lexer.hits = map[string][]int{}
+		for state, list := range coverageRules {
+			lexer.hits[state] = make([]int, len(list))
+		}
+	}
+	return lexer
+}
+
+func coverageNewInput(source io.Reader) coverageInput {
+	return coverageInput{
+		source: source,
+		pos:    CoveragePos{Line: 1, Col: 1},
+	}
+}
+
+// Next returns the next token, running the actions of the rules
+// matched on the way. It returns io.EOF at the end of the input.
+func (lexer *CoverageLexer) Next() (CoverageToken, error) {
+	for {
+		if _, n := lexer.in.peek(0); n == 0 {
+			if lexer.in.err != nil {
+				return CoverageToken{}, lexer.in.err
+			}
+			if !CoverageWRAP || lexer.Wrap == nil {
+				return CoverageToken{}, io.EOF
+			}
+			next := lexer.Wrap()
+			if next == nil {
+				return CoverageToken{}, io.EOF
+			}
+			lexer.in = coverageNewInput(next)
+			continue
+		}
+		m, ok := coverageMatchers[lexer.state]
+		if !ok {
+			return CoverageToken{}, fmt.Errorf("unknown state %s", lexer.state)
+		}
+		index, size := m.match(&lexer.in)
+		pos := lexer.in.pos
+		if index < 0 {
+			end := 0
+			for end < 20 {
+				_, n := lexer.in.peek(end)
+				if n == 0 {
+					break
+				}
+				end += n
+			}
+			return CoverageToken{}, fmt.Errorf("SYNTAX ERROR @ %d:%d [%s]", pos.Line, pos.Col, lexer.in.token(end))
+		}
+		token := &CoverageToken{
+			Text:  string(lexer.in.token(size)),
+			Pos:   pos,
+			State: lexer.state,
+		}
+		if CoverageDEBUG {
+			fmt.Fprintf(os.Stderr, "%d:%d [%s] rule %d: %q\n", pos.Line, pos.Col, lexer.state, index, token.Text)
+		}
+		if CoverageCOVERAGE {
+			lexer.hits[lexer.state][index]++
+		}
+		lexer.in.advance(size)
+		token.EndPos = lexer.in.pos
+
+		lexer.token = nil
+		if err := coverageRules[lexer.state][index].call(lexer, token); err != nil {
+			return CoverageToken{}, err
+		}
+		if lexer.token != nil {
+			return *lexer.token, nil
+		}
+	}
+}
+
+func (rule *CoverageRule) call(lexer *CoverageLexer, token *CoverageToken) error {
+	return rule.action(lexer, token)
+}
+
+// Coverage writes the times each rule fired per state, then the rules
+// that never fired and the states never entered (COVERAGE), as
+// piglex coverage does
+func (lexer *CoverageLexer) Coverage(w io.Writer) {
+	if !CoverageCOVERAGE {
+		fmt.Fprintf(w, "coverage not enabled\n")
+		return
+	}
+	fired := map[string]bool{}
+	for _, state := range coverageStateNames {
+		if lexer.entered(state) {
+			fmt.Fprintf(w, "%s\n", state)
+		} else {
+			fmt.Fprintf(w, "%s (never entered)\n", state)
+		}
+		for i, rule := range coverageRules[state] {
+			fmt.Fprintf(w, "  %10d  %s  %s\n", lexer.hits[state][i], rule.pos, rule.regexp)
+			if lexer.hits[state][i] > 0 {
+				fired[rule.pos] = true
+			}
+		}
+	}
+	unfired := []*CoverageRule{}
+	seen := map[string]bool{}
+	for _, state := range coverageStateNames {
+		for _, rule := range coverageRules[state] {
+			if !seen[rule.pos] {
+				seen[rule.pos] = true
+				if !fired[rule.pos] {
+					unfired = append(unfired, rule)
+				}
+			}
+		}
+	}
+	fmt.Fprintf(w, "%d of %d rule(s) fired\n", len(seen)-len(unfired), len(seen))
+	for _, rule := range unfired {
+		fmt.Fprintf(w, "never fired: %s  %s\n", rule.pos, rule.regexp)
+	}
+	for _, state := range coverageStateNames {
+		if !lexer.entered(state) {
+			fmt.Fprintf(w, "never entered: %s\n", state)
+		}
+	}
+}
+
+// entered tells if a rule fired in a state
+func (lexer *CoverageLexer) entered(state string) bool {
+	for _, hits := range lexer.hits[state] {
+		if hits > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// emit is called by the actions returning a token
+func (lexer *CoverageLexer) emit(id CoverageTokenID, token *CoverageToken) error {
+	token.ID = id
+	lexer.token = token
+	return nil
+}
+
+func (token CoverageToken) String() string {
+	return fmt.Sprintf("%d:%d %s %q", token.Pos.Line, token.Pos.Col, token.ID, token.Text)
+}
+
+// TokenName returns the name of a token: PRINT for TOKEN_PRINT,
+// the quoted character for ids below 256
+func CoverageTokenName(id CoverageTokenID) string {
+	if name, ok := coverageTokenNames[id]; ok {
+		return name
+	}
+	if id >= 0 && id < 256 {
+		return strconv.QuoteRune(rune(id))
+	}
+	return strconv.Itoa(int(id))
+}
+
+func (id CoverageTokenID) String() string {
+	return CoverageTokenName(id)
+}
+
+func (id CoverageStateID) String() string {
+	if id >= 0 && int(id) < len(coverageStateNames) {
+		return coverageStateNames[id]
+	}
+	return strconv.Itoa(int(id))
+}
+
+// --- init_code.go ---
+
+func coverageNormalize(value string) string {
+	return strings.ToUpper(value)
+}
+
+// --- coverage.pigl ---
+
+const (
+	CoverageTOKEN_PRINT CoverageTokenID = 256 + iota
+	CoverageTOKEN_INPUT
+	CoverageTOKEN_GOTO
+	CoverageTOKEN_LABEL
+	CoverageTOKEN_STRING
+	CoverageTOKEN_QUOTE
+	CoverageTOKEN_NAME
+)
+
+const (
+	CoverageSTATE_INIT CoverageStateID = iota
+	CoverageSTATE_STRING
+)
+
+const (
+	CoverageDEBUG    = false
+	CoverageWRAP     = false
+	CoverageUNICODE  = true
+	CoverageCOVERAGE = true
+)
+
+var (
+	coverageRules = map[string][]*CoverageRule{
+		"_INIT": {
+			{`[ \t\r\n]+`, coverageAction_0, "../../sample.pigl:22:1"},
+			{`INPUT`, coverageAction_1, "../../sample.pigl:23:1"},
+			{`PRINT`, coverageAction_2, "../../sample.pigl:24:1"},
+			{`GOTO`, coverageAction_3, "../../sample.pigl:25:1"},
+			{`LABEL`, coverageAction_4, "../../sample.pigl:26:1"},
+			{`"`, coverageAction_5, "../../sample.pigl:27:1"},
+			{`[^ \t\r\n"]+`, coverageAction_6, "../../sample.pigl:31:1"},
+		},
+		"_STRING": {
+			{`[^"]+`, coverageAction_7, "../../sample.pigl:35:1"},
+			{`"`, coverageAction_8, "../../sample.pigl:36:1"},
+		},
+	}
+	coverageDfaTables  = map[string]*coverageDfaTable{}
+	coverageTokenNames = map[CoverageTokenID]string{
+		CoverageTOKEN_PRINT:  "PRINT",
+		CoverageTOKEN_INPUT:  "INPUT",
+		CoverageTOKEN_GOTO:   "GOTO",
+		CoverageTOKEN_LABEL:  "LABEL",
+		CoverageTOKEN_STRING: "STRING",
+		CoverageTOKEN_QUOTE:  "QUOTE",
+		CoverageTOKEN_NAME:   "NAME",
+	}
+	CoverageTokenByName = map[string]CoverageTokenID{
+		"PRINT":  CoverageTOKEN_PRINT,
+		"INPUT":  CoverageTOKEN_INPUT,
+		"GOTO":   CoverageTOKEN_GOTO,
+		"LABEL":  CoverageTOKEN_LABEL,
+		"STRING": CoverageTOKEN_STRING,
+		"QUOTE":  CoverageTOKEN_QUOTE,
+		"NAME":   CoverageTOKEN_NAME,
+	}
+	coverageStateNames = []string{
+		"_INIT",
+		"_STRING",
+	}
+)
+
+// [ \t\r\n]+
+func coverageAction_0(lexer *CoverageLexer, token *CoverageToken) error {
+	return nil
+}
+
+// INPUT
+func coverageAction_1(lexer *CoverageLexer, token *CoverageToken) error {
+	return lexer.emit(CoverageTOKEN_INPUT, token)
+}
+
+// PRINT
+func coverageAction_2(lexer *CoverageLexer, token *CoverageToken) error {
+	return lexer.emit(CoverageTOKEN_PRINT, token)
+}
+
+// GOTO
+func coverageAction_3(lexer *CoverageLexer, token *CoverageToken) error {
+	return lexer.emit(CoverageTOKEN_GOTO, token)
+}
+
+// LABEL
+func coverageAction_4(lexer *CoverageLexer, token *CoverageToken) error {
+	return lexer.emit(CoverageTOKEN_LABEL, token)
+}
+
+// "
+func coverageAction_5(lexer *CoverageLexer, token *CoverageToken) error {
+	lexer.state = "_STRING"
+	return lexer.emit(CoverageTOKEN_QUOTE, token)
+}
+
+// [^ \t\r\n"]+
+func coverageAction_6(lexer *CoverageLexer, token *CoverageToken) error {
+	return lexer.emit(CoverageTOKEN_NAME, token)
+}
+
+// [^"]+
+func coverageAction_7(lexer *CoverageLexer, token *CoverageToken) error {
+	return lexer.emit(CoverageTOKEN_STRING, token)
+}
+
+// "
+func coverageAction_8(lexer *CoverageLexer, token *CoverageToken) error {
+	lexer.state = "_INIT"
+	return lexer.emit(CoverageTOKEN_QUOTE, token)
+}
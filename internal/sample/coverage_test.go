@@ -0,0 +1,182 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package sample
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/peergum/piglex"
+)
+
+//
+// TestCoverage checks the coverage of the runtime lexer, and that the
+// lexer generated with coverage reports the same
+//
+func TestCoverage(t *testing.T) {
+	spec, err := piglex.ParseFile("coverage.pigl", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	basic, err := ioutil.ReadFile("../../testdata/sample.basic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		input      string
+		hits       map[string]int // by rule position, in _INIT and _STRING
+		unfired    []string
+		notEntered []string
+		fired      string
+	}{
+		{
+			"sample.basic",
+			string(basic),
+			map[string]int{
+				"_INIT 22":   6,
+				"_INIT 24":   1,
+				"_INIT 27":   3,
+				"_INIT 31":   3,
+				"_STRING 35": 3,
+				"_STRING 36": 3,
+			},
+			[]string{"INPUT", "GOTO", "LABEL"},
+			nil,
+			"6 of 9 rule(s) fired",
+		},
+		{
+			"no strings",
+			"PRINT x\nGOTO y\n",
+			map[string]int{
+				"_INIT 22": 4,
+				"_INIT 24": 1,
+				"_INIT 25": 1,
+				"_INIT 31": 2,
+			},
+			[]string{"INPUT", "LABEL", `"`, `[^"]+`, `"`},
+			[]string{"_STRING"},
+			"4 of 9 rule(s) fired",
+		},
+		{
+			"empty",
+			"",
+			map[string]int{},
+			[]string{`[ \t\r\n]+`, "INPUT", "PRINT", "GOTO", "LABEL", `"`, `[^ \t\r\n"]+`, `[^"]+`, `"`},
+			[]string{"_INIT", "_STRING"},
+			"0 of 9 rule(s) fired",
+		},
+	}
+	for _, test := range tests {
+		coverage := piglex.NewCoverage(spec)
+		lexer, err := piglex.NewLexer(spec, strings.NewReader(test.input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lexer.Coverage = coverage
+		lexer.IgnoreMacros()
+		for {
+			if _, err := lexer.Next(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s: %s", test.name, err)
+			}
+		}
+
+		for _, state := range []string{"_INIT", "_STRING"} {
+			for _, i := range spec.RulesFor(state) {
+				rule := spec.Rules[i]
+				key := state + " " + strings.Split(rule.Pos.String(), ":")[1]
+				if got := coverage.Hits(rule, state); got != test.hits[key] {
+					t.Errorf("%s: rule %s fired %d time(s) in %s, want %d", test.name, rule.Pos, got, state, test.hits[key])
+				}
+			}
+			if entered := coverage.Entered(state); entered == contains(test.notEntered, state) {
+				t.Errorf("%s: Entered(%s) = %t", test.name, state, entered)
+			}
+		}
+		unfired := []string{}
+		for _, rule := range coverage.Unfired() {
+			unfired = append(unfired, rule.Pattern)
+		}
+		if strings.Join(unfired, " ") != strings.Join(test.unfired, " ") {
+			t.Errorf("%s: unfired %q, want %q", test.name, unfired, test.unfired)
+		}
+		if got := coverage.NotEntered(); strings.Join(got, " ") != strings.Join(test.notEntered, " ") {
+			t.Errorf("%s: not entered %q, want %q", test.name, got, test.notEntered)
+		}
+
+		var report bytes.Buffer
+		coverage.Report(&report)
+		if !strings.Contains(report.String(), "\n"+test.fired+"\n") {
+			t.Errorf("%s: report without %q:\n%s", test.name, test.fired, report.String())
+		}
+		if got := strings.Count(report.String(), "never fired: "); got != len(test.unfired) {
+			t.Errorf("%s: %d rule(s) never fired in the report, want %d", test.name, got, len(test.unfired))
+		}
+		if got := strings.Count(report.String(), "never entered: "); got != len(test.notEntered) {
+			t.Errorf("%s: %d state(s) never entered in the report, want %d", test.name, got, len(test.notEntered))
+		}
+
+		generated := CoverageNewLexer(strings.NewReader(test.input))
+		for {
+			if _, err := generated.Next(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s: generated lexer: %s", test.name, err)
+			}
+		}
+		var generatedReport bytes.Buffer
+		generated.Coverage(&generatedReport)
+		if generatedReport.String() != report.String() {
+			t.Errorf("%s: generated lexer reports\n%s\nwant\n%s", test.name, generatedReport.String(), report.String())
+		}
+	}
+}
+
+//
+// TestCoverageNotEnabled checks lexers generated without coverage
+// say so instead of reporting
+//
+func TestCoverageNotEnabled(t *testing.T) {
+	regexpLexer := RegexpNewLexer(strings.NewReader("PRINT x"))
+	dfaLexer := DfaNewLexer(strings.NewReader("PRINT x"))
+	for name, coverage := range map[string]func(io.Writer){
+		"regexp": regexpLexer.Coverage,
+		"dfa":    dfaLexer.Coverage,
+	} {
+		var buf bytes.Buffer
+		coverage(&buf)
+		if got := buf.String(); got != "coverage not enabled\n" {
+			t.Errorf("%s: got %q", name, got)
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
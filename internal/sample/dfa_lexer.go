@@ -0,0 +1,625 @@
+// Code generated by piglex from dfa.pigl. DO NOT EDIT.
+
+package sample
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// --- lexer.go ---
+
+const (
+	DfaVERSION = "0.1"
+)
+
+type DfaRule struct {
+	regexp string
+	action func(*DfaLexer, *DfaToken) error
+	pos    string // position of the rule in the .pigl file
+}
+
+// Pos is a position in the input: Offset in bytes from the start,
+// Line and Col (in runes) from 1
+type DfaPos struct {
+	Offset int
+	Line   int
+	Col    int
+}
+
+// TokenID is the id of a token (TOKEN_ constants), StateID the id
+// of a state (STATE_ constants)
+type DfaTokenID int
+type DfaStateID int
+
+// Token is a token found by the lexer, from Pos to EndPos (excluded),
+// in State
+type DfaToken struct {
+	ID     DfaTokenID
+	Text   string
+	Pos    DfaPos
+	EndPos DfaPos
+	State  string
+}
+
+// matcher finds the rule matching the longest prefix of the input,
+// returning its index in the rules of the state and the match length.
+// When several rules match the same length, the first one wins.
+type dfaMatcher interface {
+	match(in *dfaInput) (int, int)
+}
+
+// regexpMatcher holds all the rules of a state compiled into a single
+// regexp, each rule being a group of the alternation. full holds each
+// rule alone, to break ties.
+type dfaRegexpMatcher struct {
+	regexp *regexp.Regexp
+	groups []int
+	full   []*regexp.Regexp
+}
+
+// dfaTable is a DFA generated by piglex (dfa backend): runes map to
+// classes, base[state]+class is the index of the next state in trans
+type dfaDfaTable struct {
+	classes  []dfaDfaRange
+	nclasses int
+	base     []int32
+	trans    []int32
+	accept   []int32
+	latin    []int32
+}
+
+type dfaDfaRange struct {
+	lo, hi rune
+	class  int32
+}
+
+// input buffers the source from the start of the current token,
+// reading more as the matchers need it
+type dfaInput struct {
+	source io.Reader
+	buffer []byte
+	start  int    // start of the token in buffer
+	pos    DfaPos // position of the token in the source
+	eof    bool
+	err    error
+}
+
+// runeReader reads the input from the start of the token,
+// for the regexp matchers
+type dfaRuneReader struct {
+	in  *dfaInput
+	pos int
+}
+
+type DfaLexer struct {
+	Wrap func() io.Reader // next input, at the end of one (WRAP)
+
+	state string
+	in    dfaInput
+	token *DfaToken        // emitted by the last action
+	hits  map[string][]int // times each rule fired, per state (COVERAGE)
+}
+
+var (
+	dfaMatchers = map[string]dfaMatcher{}
+)
+
+func init() {
+	for state, list := range dfaRules {
+		if table, ok := dfaDfaTables[state]; ok {
+			table.init()
+			dfaMatchers[state] = table
+		} else {
+			dfaMatchers[state] = dfaNewMatcher(list)
+		}
+	}
+}
+
+// newMatcher compiles the rules of a state once, as a leftmost-longest
+// alternation: (rule1)|(rule2)|...
+func dfaNewMatcher(rules []*DfaRule) *dfaRegexpMatcher {
+	m := &dfaRegexpMatcher{
+		groups: make([]int, len(rules)),
+		full:   make([]*regexp.Regexp, len(rules)),
+	}
+	pattern := ""
+	group := 1
+	for i, rule := range rules {
+		re := regexp.MustCompile(rule.regexp)
+		m.full[i] = regexp.MustCompile("^(?:" + rule.regexp + ")$")
+		if i > 0 {
+			pattern += "|"
+		}
+		pattern += "(" + rule.regexp + ")"
+		m.groups[i] = group
+		group += 1 + re.NumSubexp()
+	}
+	m.regexp = regexp.MustCompile("^(?:" + pattern + ")")
+	m.regexp.Longest()
+	return m
+}
+
+// match returns the rule matching the longest prefix of input, and
+// the length of the match. The regexp reads the input as long as the
+// match can be extended. It tells which rule matched, but not the
+// first of the rules giving the same length: the rules before it are
+// checked against the token.
+func (m *dfaRegexpMatcher) match(in *dfaInput) (int, int) {
+	loc := m.regexp.FindReaderSubmatchIndex(&dfaRuneReader{in: in})
+	if loc == nil || loc[1] == 0 {
+		return -1, 0
+	}
+	token := in.token(loc[1])
+	for i, group := range m.groups {
+		if loc[2*group] >= 0 {
+			return i, loc[1]
+		}
+		if m.full[i].Match(token) {
+			return i, loc[1]
+		}
+	}
+	return -1, 0
+}
+
+// init builds the class lookup table of the first 256 runes
+func (table *dfaDfaTable) init() {
+	latin := make([]int32, 256)
+	for r := range latin {
+		latin[r] = table.class(rune(r))
+	}
+	table.latin = latin
+}
+
+func (table *dfaDfaTable) class(r rune) int32 {
+	if r < rune(len(table.latin)) {
+		return table.latin[r]
+	}
+	i := sort.Search(len(table.classes), func(i int) bool { return table.classes[i].hi >= r })
+	if i < len(table.classes) && table.classes[i].lo <= r {
+		return table.classes[i].class
+	}
+	return -1
+}
+
+// match runs the DFA as long as it can, and backs up to the last
+// accepting state
+func (table *dfaDfaTable) match(in *dfaInput) (int, int) {
+	state := int32(0)
+	rule, size := -1, 0
+	for i := 0; ; {
+		r, n := in.peek(i)
+		if n == 0 {
+			break
+		}
+		if !DfaUNICODE {
+			// runes are bytes
+			r, n = rune(in.buffer[in.start+i]), 1
+		}
+		class := table.class(r)
+		if class < 0 {
+			break
+		}
+		state = table.trans[table.base[state]+class]
+		if state < 0 {
+			break
+		}
+		i += n
+		if accept := table.accept[state]; accept >= 0 {
+			rule, size = int(accept), i
+		}
+	}
+	return rule, size
+}
+
+// peek decodes the rune at i bytes from the start of the token, reading
+// more of the source if needed. It returns a size of 0 at the end.
+func (in *dfaInput) peek(i int) (rune, int) {
+	for !in.eof && len(in.buffer)-(in.start+i) < utf8.UTFMax {
+		in.fill()
+	}
+	pos := in.start + i
+	if pos >= len(in.buffer) {
+		return 0, 0
+	}
+	if c := in.buffer[pos]; c < utf8.RuneSelf {
+		return rune(c), 1
+	}
+	return utf8.DecodeRune(in.buffer[pos:])
+}
+
+// fill drops what's before the token and reads more of the source
+func (in *dfaInput) fill() {
+	if in.start > 0 {
+		n := copy(in.buffer, in.buffer[in.start:])
+		in.buffer = in.buffer[:n]
+		in.start = 0
+	}
+	if len(in.buffer) == cap(in.buffer) {
+		buffer := make([]byte, len(in.buffer), 2*cap(in.buffer)+4096)
+		copy(buffer, in.buffer)
+		in.buffer = buffer
+	}
+	n, err := in.source.Read(in.buffer[len(in.buffer):cap(in.buffer)])
+	in.buffer = in.buffer[:len(in.buffer)+n]
+	if err != nil {
+		in.eof = true
+		if err != io.EOF {
+			in.err = err
+		}
+	}
+}
+
+// token returns the first size bytes from the start of the token
+func (in *dfaInput) token(size int) []byte {
+	return in.buffer[in.start : in.start+size]
+}
+
+// advance moves the start of the next token, after size bytes
+func (in *dfaInput) advance(size int) {
+	for _, c := range in.token(size) {
+		switch {
+		case c == '\n':
+			in.pos.Line++
+			in.pos.Col = 1
+		case DfaUNICODE && !utf8.RuneStart(c):
+			// continuation byte of a rune
+		default:
+			in.pos.Col++
+		}
+	}
+	in.start += size
+	in.pos.Offset += size
+}
+
+func (reader *dfaRuneReader) ReadRune() (rune, int, error) {
+	r, n := reader.in.peek(reader.pos)
+	if n == 0 {
+		return 0, 0, io.EOF
+	}
+	reader.pos += n
+	return r, n, nil
+}
+
+func DfaNewLexer(source io.Reader) *DfaLexer {
+	lexer := &DfaLexer{
+		state: "_INIT",
+		in:    dfaNewInput(source),
+	}
+	if DfaCOVERAGE {
+		lexer.hits = map[string][]int{}
+		for state, list := range dfaRules {
+			lexer.hits[state] = make([]int, len(list))
+		}
+	}
+	return lexer
+}
+
+func dfaNewInput(source io.Reader) dfaInput {
+	return dfaInput{
+		source: source,
+		pos:    DfaPos{Line: 1, Col: 1},
+	}
+}
+
+// Next returns the next token, running the actions of the rules
+// matched on the way. It returns io.EOF at the end of the input.
+func (lexer *DfaLexer) Next() (DfaToken, error) {
+	for {
+		if _, n := lexer.in.peek(0); n == 0 {
+			if lexer.in.err != nil {
+				return DfaToken{}, lexer.in.err
+			}
+			if !DfaWRAP || lexer.Wrap == nil {
+				return DfaToken{}, io.EOF
+			}
+			next := lexer.Wrap()
+			if next == nil {
+				return DfaToken{}, io.EOF
+			}
+			lexer.in = dfaNewInput(next)
+			continue
+		}
+		m, ok := dfaMatchers[lexer.state]
+		if !ok {
+			return DfaToken{}, fmt.Errorf("unknown state %s", lexer.state)
+		}
+		index, size := m.match(&lexer.in)
+		pos := lexer.in.pos
+		if index < 0 {
+			end := 0
+			for end < 20 {
+				_, n := lexer.in.peek(end)
+				if n == 0 {
+					break
+				}
+				end += n
+			}
+			return DfaToken{}, fmt.Errorf("SYNTAX ERROR @ %d:%d [%s]", pos.Line, pos.Col, lexer.in.token(end))
+		}
+		token := &DfaToken{
+			Text:  string(lexer.in.token(size)),
+			Pos:   pos,
+			State: lexer.state,
+		}
+		if DfaDEBUG {
+			fmt.Fprintf(os.Stderr, "%d:%d [%s] rule %d: %q\n", pos.Line, pos.Col, lexer.state, index, token.Text)
+		}
+		if DfaCOVERAGE {
+			lexer.hits[lexer.state][index]++
+		}
+		lexer.in.advance(size)
+		token.EndPos = lexer.in.pos
+
+		lexer.token = nil
+		if err := dfaRules[lexer.state][index].call(lexer, token); err != nil {
+			return DfaToken{}, err
+		}
+		if lexer.token != nil {
+			return *lexer.token, nil
+		}
+	}
+}
+
+func (rule *DfaRule) call(lexer *DfaLexer, token *DfaToken) error {
+	return rule.action(lexer, token)
+}
+
+// Coverage writes the times each rule fired per state, then the rules
+// that never fired and the states never entered (COVERAGE), as
+// piglex coverage does
+func (lexer *DfaLexer) Coverage(w io.Writer) {
+	if !DfaCOVERAGE {
+		fmt.Fprintf(w, "coverage not enabled\n")
+		return
+	}
+	fired := map[string]bool{}
+	for _, state := range dfaStateNames {
+		if lexer.entered(state) {
+			fmt.Fprintf(w, "%s\n", state)
+		} else {
+			fmt.Fprintf(w, "%s (never entered)\n", state)
+		}
+		for i, rule := range dfaRules[state] {
+			fmt.Fprintf(w, "  %10d  %s  %s\n", lexer.hits[state][i], rule.pos, rule.regexp)
+			if lexer.hits[state][i] > 0 {
+				fired[rule.pos] = true
+			}
+		}
+	}
+	unfired := []*DfaRule{}
+	seen := map[string]bool{}
+	for _, state := range dfaStateNames {
+		for _, rule := range dfaRules[state] {
+			if !seen[rule.pos] {
+				seen[rule.pos] = true
+				if !fired[rule.pos] {
+					unfired = append(unfired, rule)
+				}
+			}
+		}
+	}
+	fmt.Fprintf(w, "%d of %d rule(s) fired\n", len(seen)-len(unfired), len(seen))
+	for _, rule := range unfired {
+		fmt.Fprintf(w, "never fired: %s  %s\n", rule.pos, rule.regexp)
+	}
+	for _, state := range dfaStateNames {
+		if !lexer.entered(state) {
+			fmt.Fprintf(w, "never entered: %s\n", state)
+		}
+	}
+}
+
+// entered tells if a rule fired in a state
+func (lexer *DfaLexer) entered(state string) bool {
+	for _, hits := range lexer.hits[state] {
+		if hits > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// emit is called by the actions returning a token
+func (lexer *DfaLexer) emit(id DfaTokenID, token *DfaToken) error {
+	token.ID = id
+	lexer.token = token
+	return nil
+}
+
+func (token DfaToken) String() string {
+	return fmt.Sprintf("%d:%d %s %q", token.Pos.Line, token.Pos.Col, token.ID, token.Text)
+}
+
+// TokenName returns the name of a token: PRINT for TOKEN_PRINT,
+// the quoted character for ids below 256
+func DfaTokenName(id DfaTokenID) string {
+	if name, ok := dfaTokenNames[id]; ok {
+		return name
+	}
+	if id >= 0 && id < 256 {
+		return strconv.QuoteRune(rune(id))
+	}
+	return strconv.Itoa(int(id))
+}
+
+func (id DfaTokenID) String() string {
+	return DfaTokenName(id)
+}
+
+func (id DfaStateID) String() string {
+	if id >= 0 && int(id) < len(dfaStateNames) {
+		return dfaStateNames[id]
+	}
+	return strconv.Itoa(int(id))
+}
+
+// --- init_code.go ---
+
+func dfaNormalize(value string) string {
+	return strings.ToUpper(value)
+}
+
+// --- dfa.pigl ---
+
+const (
+	DfaTOKEN_PRINT DfaTokenID = 256 + iota
+	DfaTOKEN_INPUT
+	DfaTOKEN_GOTO
+	DfaTOKEN_LABEL
+	DfaTOKEN_STRING
+	DfaTOKEN_QUOTE
+	DfaTOKEN_NAME
+)
+
+const (
+	DfaSTATE_INIT DfaStateID = iota
+	DfaSTATE_STRING
+)
+
+const (
+	DfaDEBUG    = false
+	DfaWRAP     = false
+	DfaUNICODE  = true
+	DfaCOVERAGE = false
+)
+
+var (
+	dfaRules = map[string][]*DfaRule{
+		"_INIT": {
+			{`[ \t\r\n]+`, dfaAction_0, "../../sample.pigl:22:1"},
+			{`INPUT`, dfaAction_1, "../../sample.pigl:23:1"},
+			{`PRINT`, dfaAction_2, "../../sample.pigl:24:1"},
+			{`GOTO`, dfaAction_3, "../../sample.pigl:25:1"},
+			{`LABEL`, dfaAction_4, "../../sample.pigl:26:1"},
+			{`"`, dfaAction_5, "../../sample.pigl:27:1"},
+			{`[^ \t\r\n"]+`, dfaAction_6, "../../sample.pigl:31:1"},
+		},
+		"_STRING": {
+			{`[^"]+`, dfaAction_7, "../../sample.pigl:35:1"},
+			{`"`, dfaAction_8, "../../sample.pigl:36:1"},
+		},
+	}
+	dfaDfaTables = map[string]*dfaDfaTable{
+		"_INIT": {
+			classes:  []dfaDfaRange{{0, 8, 0}, {9, 10, 1}, {11, 12, 0}, {13, 13, 1}, {14, 31, 0}, {32, 32, 1}, {33, 33, 0}, {34, 34, 2}, {35, 64, 0}, {65, 65, 3}, {66, 66, 4}, {67, 68, 0}, {69, 69, 5}, {70, 70, 0}, {71, 71, 6}, {72, 72, 0}, {73, 73, 7}, {74, 75, 0}, {76, 76, 8}, {77, 77, 0}, {78, 78, 9}, {79, 79, 10}, {80, 80, 11}, {81, 81, 0}, {82, 82, 12}, {83, 83, 0}, {84, 84, 13}, {85, 85, 14}, {86, 1114111, 0}},
+			nclasses: 15,
+			base: []int32{
+				0, 15, 30, 45, 60, 75, 90, 105, 120, 135, 150, 165, 180, 195, 210, 225,
+				15, 240, 255, 270, 15, 15, 15,
+			},
+			trans: []int32{
+				1, 2, 3, 1, 1, 1, 4, 5, 6, 1, 1, 7, 1, 1, 1, 1,
+				-1, -1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, 2,
+				-1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1,
+				-1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, 1, -1, -1, 1,
+				1, 1, 1, 1, 1, 1, 8, 1, 1, 1, 1, 1, -1, -1, 1, 1,
+				1, 1, 1, 1, 9, 1, 1, 1, 1, 1, 1, -1, -1, 10, 1, 1,
+				1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1, 1, 1, 1, 1,
+				1, 1, 1, 1, 1, 11, 1, 1, 1, -1, -1, 1, 1, 1, 1, 1,
+				1, 1, 1, 1, 1, 12, 1, 1, -1, -1, 1, 1, 1, 1, 1, 1,
+				1, 1, 13, 1, 1, 1, 1, -1, -1, 1, 14, 1, 1, 1, 1, 1,
+				1, 1, 1, 1, 1, 1, -1, -1, 1, 1, 1, 1, 15, 1, 1, 1,
+				1, 1, 1, 1, 1, -1, -1, 1, 1, 1, 1, 1, 1, 1, 16, 1,
+				1, 1, 1, 1, -1, -1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+				1, 17, 1, -1, -1, 1, 1, 18, 1, 1, 1, 1, 1, 1, 1, 1,
+				1, 1, -1, -1, 1, 1, 1, 1, 1, 1, 19, 1, 1, 1, 1, 1,
+				1, -1, -1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 20, 1, 1,
+				-1, -1, 1, 1, 1, 1, 1, 21, 1, 1, 1, 1, 1, 1, 1, -1,
+				-1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 22, 1,
+			},
+			accept: []int32{
+				-1, 6, 0, 5, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6,
+				3, 6, 6, 6, 1, 4, 2,
+			},
+		},
+		"_STRING": {
+			classes:  []dfaDfaRange{{0, 33, 0}, {34, 34, 1}, {35, 1114111, 0}},
+			nclasses: 2,
+			base: []int32{
+				0, 2, 4,
+			},
+			trans: []int32{
+				1, 2, 1, -1, -1, -1,
+			},
+			accept: []int32{
+				-1, 0, 1,
+			},
+		},
+	}
+	dfaTokenNames = map[DfaTokenID]string{
+		DfaTOKEN_PRINT:  "PRINT",
+		DfaTOKEN_INPUT:  "INPUT",
+		DfaTOKEN_GOTO:   "GOTO",
+		DfaTOKEN_LABEL:  "LABEL",
+		DfaTOKEN_STRING: "STRING",
+		DfaTOKEN_QUOTE:  "QUOTE",
+		DfaTOKEN_NAME:   "NAME",
+	}
+	DfaTokenByName = map[string]DfaTokenID{
+		"PRINT":  DfaTOKEN_PRINT,
+		"INPUT":  DfaTOKEN_INPUT,
+		"GOTO":   DfaTOKEN_GOTO,
+		"LABEL":  DfaTOKEN_LABEL,
+		"STRING": DfaTOKEN_STRING,
+		"QUOTE":  DfaTOKEN_QUOTE,
+		"NAME":   DfaTOKEN_NAME,
+	}
+	dfaStateNames = []string{
+		"_INIT",
+		"_STRING",
+	}
+)
+
+// [ \t\r\n]+
+func dfaAction_0(lexer *DfaLexer, token *DfaToken) error {
+	return nil
+}
+
+// INPUT
+func dfaAction_1(lexer *DfaLexer, token *DfaToken) error {
+	return lexer.emit(DfaTOKEN_INPUT, token)
+}
+
+// PRINT
+func dfaAction_2(lexer *DfaLexer, token *DfaToken) error {
+	return lexer.emit(DfaTOKEN_PRINT, token)
+}
+
+// GOTO
+func dfaAction_3(lexer *DfaLexer, token *DfaToken) error {
+	return lexer.emit(DfaTOKEN_GOTO, token)
+}
+
+// LABEL
+func dfaAction_4(lexer *DfaLexer, token *DfaToken) error {
+	return lexer.emit(DfaTOKEN_LABEL, token)
+}
+
+// "
+func dfaAction_5(lexer *DfaLexer, token *DfaToken) error {
+	lexer.state = "_STRING"
+	return lexer.emit(DfaTOKEN_QUOTE, token)
+}
+
+// [^ \t\r\n"]+
+func dfaAction_6(lexer *DfaLexer, token *DfaToken) error {
+	return lexer.emit(DfaTOKEN_NAME, token)
+}
+
+// [^"]+
+func dfaAction_7(lexer *DfaLexer, token *DfaToken) error {
+	return lexer.emit(DfaTOKEN_STRING, token)
+}
+
+// "
+func dfaAction_8(lexer *DfaLexer, token *DfaToken) error {
+	lexer.state = "_INIT"
+	return lexer.emit(DfaTOKEN_QUOTE, token)
+}
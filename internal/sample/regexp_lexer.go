@@ -0,0 +1,579 @@
+// Code generated by piglex from regexp.pigl. DO NOT EDIT.
+
+package sample
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// --- lexer.go ---
+
+const (
+	RegexpVERSION = "0.1"
+)
+
+type RegexpRule struct {
+	regexp string
+	action func(*RegexpLexer, *RegexpToken) error
+	pos    string // position of the rule in the .pigl file
+}
+
+// Pos is a position in the input: Offset in bytes from the start,
+// Line and Col (in runes) from 1
+type RegexpPos struct {
+	Offset int
+	Line   int
+	Col    int
+}
+
+// TokenID is the id of a token (TOKEN_ constants), StateID the id
+// of a state (STATE_ constants)
+type RegexpTokenID int
+type RegexpStateID int
+
+// Token is a token found by the lexer, from Pos to EndPos (excluded),
+// in State
+type RegexpToken struct {
+	ID     RegexpTokenID
+	Text   string
+	Pos    RegexpPos
+	EndPos RegexpPos
+	State  string
+}
+
+// matcher finds the rule matching the longest prefix of the input,
+// returning its index in the rules of the state and the match length.
+// When several rules match the same length, the first one wins.
+type regexpMatcher interface {
+	match(in *regexpInput) (int, int)
+}
+
+// regexpMatcher holds all the rules of a state compiled into a single
+// regexp, each rule being a group of the alternation. full holds each
+// rule alone, to break ties.
+type regexpRegexpMatcher struct {
+	regexp *regexp.Regexp
+	groups []int
+	full   []*regexp.Regexp
+}
+
+// dfaTable is a DFA generated by piglex (dfa backend): runes map to
+// classes, base[state]+class is the index of the next state in trans
+type regexpDfaTable struct {
+	classes  []regexpDfaRange
+	nclasses int
+	base     []int32
+	trans    []int32
+	accept   []int32
+	latin    []int32
+}
+
+type regexpDfaRange struct {
+	lo, hi rune
+	class  int32
+}
+
+// input buffers the source from the start of the current token,
+// reading more as the matchers need it
+type regexpInput struct {
+	source io.Reader
+	buffer []byte
+	start  int       // start of the token in buffer
+	pos    RegexpPos // position of the token in the source
+	eof    bool
+	err    error
+}
+
+// runeReader reads the input from the start of the token,
+// for the regexp matchers
+type regexpRuneReader struct {
+	in  *regexpInput
+	pos int
+}
+
+type RegexpLexer struct {
+	Wrap func() io.Reader // next input, at the end of one (WRAP)
+
+	state string
+	in    regexpInput
+	token *RegexpToken     // emitted by the last action
+	hits  map[string][]int // times each rule fired, per state (COVERAGE)
+}
+
+var (
+	regexpMatchers = map[string]regexpMatcher{}
+)
+
+func init() {
+	for state, list := range regexpRules {
+		if table, ok := regexpDfaTables[state]; ok {
+			table.init()
+			regexpMatchers[state] = table
+		} else {
+			regexpMatchers[state] = regexpNewMatcher(list)
+		}
+	}
+}
+
+// newMatcher compiles the rules of a state once, as a leftmost-longest
+// alternation: (rule1)|(rule2)|...
+func regexpNewMatcher(rules []*RegexpRule) *regexpRegexpMatcher {
+	m := &regexpRegexpMatcher{
+		groups: make([]int, len(rules)),
+		full:   make([]*regexp.Regexp, len(rules)),
+	}
+	pattern := ""
+	group := 1
+	for i, rule := range rules {
+		re := regexp.MustCompile(rule.regexp)
+		m.full[i] = regexp.MustCompile("^(?:" + rule.regexp + ")$")
+		if i > 0 {
+			pattern += "|"
+		}
+		pattern += "(" + rule.regexp + ")"
+		m.groups[i] = group
+		group += 1 + re.NumSubexp()
+	}
+	m.regexp = regexp.MustCompile("^(?:" + pattern + ")")
+	m.regexp.Longest()
+	return m
+}
+
+// match returns the rule matching the longest prefix of input, and
+// the length of the match. The regexp reads the input as long as the
+// match can be extended. It tells which rule matched, but not the
+// first of the rules giving the same length: the rules before it are
+// checked against the token.
+func (m *regexpRegexpMatcher) match(in *regexpInput) (int, int) {
+	loc := m.regexp.FindReaderSubmatchIndex(&regexpRuneReader{in: in})
+	if loc == nil || loc[1] == 0 {
+		return -1, 0
+	}
+	token := in.token(loc[1])
+	for i, group := range m.groups {
+		if loc[2*group] >= 0 {
+			return i, loc[1]
+		}
+		if m.full[i].Match(token) {
+			return i, loc[1]
+		}
+	}
+	return -1, 0
+}
+
+// init builds the class lookup table of the first 256 runes
+func (table *regexpDfaTable) init() {
+	latin := make([]int32, 256)
+	for r := range latin {
+		latin[r] = table.class(rune(r))
+	}
+	table.latin = latin
+}
+
+func (table *regexpDfaTable) class(r rune) int32 {
+	if r < rune(len(table.latin)) {
+		return table.latin[r]
+	}
+	i := sort.Search(len(table.classes), func(i int) bool { return table.classes[i].hi >= r })
+	if i < len(table.classes) && table.classes[i].lo <= r {
+		return table.classes[i].class
+	}
+	return -1
+}
+
+// match runs the DFA as long as it can, and backs up to the last
+// accepting state
+func (table *regexpDfaTable) match(in *regexpInput) (int, int) {
+	state := int32(0)
+	rule, size := -1, 0
+	for i := 0; ; {
+		r, n := in.peek(i)
+		if n == 0 {
+			break
+		}
+		if !RegexpUNICODE {
+			// runes are bytes
+			r, n = rune(in.buffer[in.start+i]), 1
+		}
+		class := table.class(r)
+		if class < 0 {
+			break
+		}
+		state = table.trans[table.base[state]+class]
+		if state < 0 {
+			break
+		}
+		i += n
+		if accept := table.accept[state]; accept >= 0 {
+			rule, size = int(accept), i
+		}
+	}
+	return rule, size
+}
+
+// peek decodes the rune at i bytes from the start of the token, reading
+// more of the source if needed. It returns a size of 0 at the end.
+func (in *regexpInput) peek(i int) (rune, int) {
+	for !in.eof && len(in.buffer)-(in.start+i) < utf8.UTFMax {
+		in.fill()
+	}
+	pos := in.start + i
+	if pos >= len(in.buffer) {
+		return 0, 0
+	}
+	if c := in.buffer[pos]; c < utf8.RuneSelf {
+		return rune(c), 1
+	}
+	return utf8.DecodeRune(in.buffer[pos:])
+}
+
+// fill drops what's before the token and reads more of the source
+func (in *regexpInput) fill() {
+	if in.start > 0 {
+		n := copy(in.buffer, in.buffer[in.start:])
+		in.buffer = in.buffer[:n]
+		in.start = 0
+	}
+	if len(in.buffer) == cap(in.buffer) {
+		buffer := make([]byte, len(in.buffer), 2*cap(in.buffer)+4096)
+		copy(buffer, in.buffer)
+		in.buffer = buffer
+	}
+	n, err := in.source.Read(in.buffer[len(in.buffer):cap(in.buffer)])
+	in.buffer = in.buffer[:len(in.buffer)+n]
+	if err != nil {
+		in.eof = true
+		if err != io.EOF {
+			in.err = err
+		}
+	}
+}
+
+// token returns the first size bytes from the start of the token
+func (in *regexpInput) token(size int) []byte {
+	return in.buffer[in.start : in.start+size]
+}
+
+// advance moves the start of the next token, after size bytes
+func (in *regexpInput) advance(size int) {
+	for _, c := range in.token(size) {
+		switch {
+		case c == '\n':
+			in.pos.Line++
+			in.pos.Col = 1
+		case RegexpUNICODE && !utf8.RuneStart(c):
+			// continuation byte of a rune
+		default:
+			in.pos.Col++
+		}
+	}
+	in.start += size
+	in.pos.Offset += size
+}
+
+func (reader *regexpRuneReader) ReadRune() (rune, int, error) {
+	r, n := reader.in.peek(reader.pos)
+	if n == 0 {
+		return 0, 0, io.EOF
+	}
+	reader.pos += n
+	return r, n, nil
+}
+
+func RegexpNewLexer(source io.Reader) *RegexpLexer {
+	lexer := &RegexpLexer{
+		state: "_INIT",
+		in:    regexpNewInput(source),
+	}
+	if RegexpCOVERAGE {
+		lexer.hits = map[string][]int{}
+		for state, list := range regexpRules {
+			lexer.hits[state] = make([]int, len(list))
+		}
+	}
+	return lexer
+}
+
+func regexpNewInput(source io.Reader) regexpInput {
+	return regexpInput{
+		source: source,
+		pos:    RegexpPos{Line: 1, Col: 1},
+	}
+}
+
+// Next returns the next token, running the actions of the rules
+// matched on the way. It returns io.EOF at the end of the input.
+func (lexer *RegexpLexer) Next() (RegexpToken, error) {
+	for {
+		if _, n := lexer.in.peek(0); n == 0 {
+			if lexer.in.err != nil {
+				return RegexpToken{}, lexer.in.err
+			}
+			if !RegexpWRAP || lexer.Wrap == nil {
+				return RegexpToken{}, io.EOF
+			}
+			next := lexer.Wrap()
+			if next == nil {
+				return RegexpToken{}, io.EOF
+			}
+			lexer.in = regexpNewInput(next)
+			continue
+		}
+		m, ok := regexpMatchers[lexer.state]
+		if !ok {
+			return RegexpToken{}, fmt.Errorf("unknown state %s", lexer.state)
+		}
+		index, size := m.match(&lexer.in)
+		pos := lexer.in.pos
+		if index < 0 {
+			end := 0
+			for end < 20 {
+				_, n := lexer.in.peek(end)
+				if n == 0 {
+					break
+				}
+				end += n
+			}
+			return RegexpToken{}, fmt.Errorf("SYNTAX ERROR @ %d:%d [%s]", pos.Line, pos.Col, lexer.in.token(end))
+		}
+		token := &RegexpToken{
+			Text:  string(lexer.in.token(size)),
+			Pos:   pos,
+			State: lexer.state,
+		}
+		if RegexpDEBUG {
+			fmt.Fprintf(os.Stderr, "%d:%d [%s] rule %d: %q\n", pos.Line, pos.Col, lexer.state, index, token.Text)
+		}
+		if RegexpCOVERAGE {
+			lexer.hits[lexer.state][index]++
+		}
+		lexer.in.advance(size)
+		token.EndPos = lexer.in.pos
+
+		lexer.token = nil
+		if err := regexpRules[lexer.state][index].call(lexer, token); err != nil {
+			return RegexpToken{}, err
+		}
+		if lexer.token != nil {
+			return *lexer.token, nil
+		}
+	}
+}
+
+func (rule *RegexpRule) call(lexer *RegexpLexer, token *RegexpToken) error {
+	return rule.action(lexer, token)
+}
+
+// Coverage writes the times each rule fired per state, then the rules
+// that never fired and the states never entered (COVERAGE), as
+// piglex coverage does
+func (lexer *RegexpLexer) Coverage(w io.Writer) {
+	if !RegexpCOVERAGE {
+		fmt.Fprintf(w, "coverage not enabled\n")
+		return
+	}
+	fired := map[string]bool{}
+	for _, state := range regexpStateNames {
+		if lexer.entered(state) {
+			fmt.Fprintf(w, "%s\n", state)
+		} else {
+			fmt.Fprintf(w, "%s (never entered)\n", state)
+		}
+		for i, rule := range regexpRules[state] {
+			fmt.Fprintf(w, "  %10d  %s  %s\n", lexer.hits[state][i], rule.pos, rule.regexp)
+			if lexer.hits[state][i] > 0 {
+				fired[rule.pos] = true
+			}
+		}
+	}
+	unfired := []*RegexpRule{}
+	seen := map[string]bool{}
+	for _, state := range regexpStateNames {
+		for _, rule := range regexpRules[state] {
+			if !seen[rule.pos] {
+				seen[rule.pos] = true
+				if !fired[rule.pos] {
+					unfired = append(unfired, rule)
+				}
+			}
+		}
+	}
+	fmt.Fprintf(w, "%d of %d rule(s) fired\n", len(seen)-len(unfired), len(seen))
+	for _, rule := range unfired {
+		fmt.Fprintf(w, "never fired: %s  %s\n", rule.pos, rule.regexp)
+	}
+	for _, state := range regexpStateNames {
+		if !lexer.entered(state) {
+			fmt.Fprintf(w, "never entered: %s\n", state)
+		}
+	}
+}
+
+// entered tells if a rule fired in a state
+func (lexer *RegexpLexer) entered(state string) bool {
+	for _, hits := range lexer.hits[state] {
+		if hits > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// emit is called by the actions returning a token
+func (lexer *RegexpLexer) emit(id RegexpTokenID, token *RegexpToken) error {
+	token.ID = id
+	lexer.token = token
+	return nil
+}
+
+func (token RegexpToken) String() string {
+	return fmt.Sprintf("%d:%d %s %q", token.Pos.Line, token.Pos.Col, token.ID, token.Text)
+}
+
+// TokenName returns the name of a token: PRINT for TOKEN_PRINT,
+// the quoted character for ids below 256
+func RegexpTokenName(id RegexpTokenID) string {
+	if name, ok := regexpTokenNames[id]; ok {
+		return name
+	}
+	if id >= 0 && id < 256 {
+		return strconv.QuoteRune(rune(id))
+	}
+	return strconv.Itoa(int(id))
+}
+
+func (id RegexpTokenID) String() string {
+	return RegexpTokenName(id)
+}
+
+func (id RegexpStateID) String() string {
+	if id >= 0 && int(id) < len(regexpStateNames) {
+		return regexpStateNames[id]
+	}
+	return strconv.Itoa(int(id))
+}
+
+// --- init_code.go ---
+
+func regexpNormalize(value string) string {
+	return strings.ToUpper(value)
+}
+
+// --- regexp.pigl ---
+
+const (
+	RegexpTOKEN_PRINT RegexpTokenID = 256 + iota
+	RegexpTOKEN_INPUT
+	RegexpTOKEN_GOTO
+	RegexpTOKEN_LABEL
+	RegexpTOKEN_STRING
+	RegexpTOKEN_QUOTE
+	RegexpTOKEN_NAME
+)
+
+const (
+	RegexpSTATE_INIT RegexpStateID = iota
+	RegexpSTATE_STRING
+)
+
+const (
+	RegexpDEBUG    = false
+	RegexpWRAP     = false
+	RegexpUNICODE  = true
+	RegexpCOVERAGE = false
+)
+
+var (
+	regexpRules = map[string][]*RegexpRule{
+		"_INIT": {
+			{`[ \t\r\n]+`, regexpAction_0, "../../sample.pigl:22:1"},
+			{`INPUT`, regexpAction_1, "../../sample.pigl:23:1"},
+			{`PRINT`, regexpAction_2, "../../sample.pigl:24:1"},
+			{`GOTO`, regexpAction_3, "../../sample.pigl:25:1"},
+			{`LABEL`, regexpAction_4, "../../sample.pigl:26:1"},
+			{`"`, regexpAction_5, "../../sample.pigl:27:1"},
+			{`[^ \t\r\n"]+`, regexpAction_6, "../../sample.pigl:31:1"},
+		},
+		"_STRING": {
+			{`[^"]+`, regexpAction_7, "../../sample.pigl:35:1"},
+			{`"`, regexpAction_8, "../../sample.pigl:36:1"},
+		},
+	}
+	regexpDfaTables  = map[string]*regexpDfaTable{}
+	regexpTokenNames = map[RegexpTokenID]string{
+		RegexpTOKEN_PRINT:  "PRINT",
+		RegexpTOKEN_INPUT:  "INPUT",
+		RegexpTOKEN_GOTO:   "GOTO",
+		RegexpTOKEN_LABEL:  "LABEL",
+		RegexpTOKEN_STRING: "STRING",
+		RegexpTOKEN_QUOTE:  "QUOTE",
+		RegexpTOKEN_NAME:   "NAME",
+	}
+	RegexpTokenByName = map[string]RegexpTokenID{
+		"PRINT":  RegexpTOKEN_PRINT,
+		"INPUT":  RegexpTOKEN_INPUT,
+		"GOTO":   RegexpTOKEN_GOTO,
+		"LABEL":  RegexpTOKEN_LABEL,
+		"STRING": RegexpTOKEN_STRING,
+		"QUOTE":  RegexpTOKEN_QUOTE,
+		"NAME":   RegexpTOKEN_NAME,
+	}
+	regexpStateNames = []string{
+		"_INIT",
+		"_STRING",
+	}
+)
+
+// [ \t\r\n]+
+func regexpAction_0(lexer *RegexpLexer, token *RegexpToken) error {
+	return nil
+}
+
+// INPUT
+func regexpAction_1(lexer *RegexpLexer, token *RegexpToken) error {
+	return lexer.emit(RegexpTOKEN_INPUT, token)
+}
+
+// PRINT
+func regexpAction_2(lexer *RegexpLexer, token *RegexpToken) error {
+	return lexer.emit(RegexpTOKEN_PRINT, token)
+}
+
+// GOTO
+func regexpAction_3(lexer *RegexpLexer, token *RegexpToken) error {
+	return lexer.emit(RegexpTOKEN_GOTO, token)
+}
+
+// LABEL
+func regexpAction_4(lexer *RegexpLexer, token *RegexpToken) error {
+	return lexer.emit(RegexpTOKEN_LABEL, token)
+}
+
+// "
+func regexpAction_5(lexer *RegexpLexer, token *RegexpToken) error {
+	lexer.state = "_STRING"
+	return lexer.emit(RegexpTOKEN_QUOTE, token)
+}
+
+// [^ \t\r\n"]+
+func regexpAction_6(lexer *RegexpLexer, token *RegexpToken) error {
+	return lexer.emit(RegexpTOKEN_NAME, token)
+}
+
+// [^"]+
+func regexpAction_7(lexer *RegexpLexer, token *RegexpToken) error {
+	return lexer.emit(RegexpTOKEN_STRING, token)
+}
+
+// "
+func regexpAction_8(lexer *RegexpLexer, token *RegexpToken) error {
+	lexer.state = "_INIT"
+	return lexer.emit(RegexpTOKEN_QUOTE, token)
+}
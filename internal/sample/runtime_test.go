@@ -0,0 +1,111 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package sample
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/peergum/piglex"
+	"github.com/peergum/piglex/piglextest"
+)
+
+//
+// TestRuntime checks the runtime lexer (piglex.NewLexer) gives the
+// same tokens as the lexers generated from the same spec
+//
+func TestRuntime(t *testing.T) {
+	spec, err := piglex.ParseFile("../../sample.pigl", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	basic, err := ioutil.ReadFile("../../testdata/sample.basic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs := map[string]string{
+		"sample.basic": string(basic),
+		"generated":    string(basicSource(256 << 10)),
+		"empty":        "",
+		"unicode":      "PRINT \"é ü\" naïve\r\nGOTO ∞\n",
+		"unterminated": "INPUT \"abc\ndef",
+		"glued":        "INPUTX PRINT\"x\"GOTO\"\"LABEL",
+		"blank":        "\n\n  \t\r\n",
+	}
+	for name, input := range inputs {
+		expected, err := piglextest.Tokenize(spec, name, strings.NewReader(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		regexpLexer := RegexpNewLexer(strings.NewReader(input))
+		actual := generatedTokens(func(w io.Writer) error {
+			token, err := regexpLexer.Next()
+			if err == nil {
+				dumpToken(w, token.Pos.Line, token.Pos.Col, RegexpTokenName(token.ID), token.Text, token.State)
+			}
+			return err
+		})
+		if diff := piglextest.Diff(expected, actual); diff != "" {
+			t.Errorf("%s: regexp backend:\n%s", name, diff)
+		}
+
+		dfaLexer := DfaNewLexer(strings.NewReader(input))
+		actual = generatedTokens(func(w io.Writer) error {
+			token, err := dfaLexer.Next()
+			if err == nil {
+				dumpToken(w, token.Pos.Line, token.Pos.Col, DfaTokenName(token.ID), token.Text, token.State)
+			}
+			return err
+		})
+		if diff := piglextest.Diff(expected, actual); diff != "" {
+			t.Errorf("%s: dfa backend:\n%s", name, diff)
+		}
+	}
+}
+
+//
+// generatedTokens dumps the tokens of a generated lexer until the end,
+// an error ending them on an error: line
+//
+func generatedTokens(next func(w io.Writer) error) []byte {
+	var buf bytes.Buffer
+	for {
+		err := next(&buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Fprintf(&buf, "error: %s\n", err)
+			break
+		}
+	}
+	return buf.Bytes()
+}
+
+//
+// dumpToken writes a token as piglex.Token.Dump does
+//
+func dumpToken(w io.Writer, line, col int, name, text, state string) {
+	fmt.Fprintf(w, "%d:%d %s %q [%s]\n", line, col, name, text, state)
+}
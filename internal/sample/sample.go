@@ -0,0 +1,30 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//
+// Package sample holds the lexers generated from sample.pigl with the
+// regexp and dfa backends (regexp_lexer.go and dfa_lexer.go), and with
+// coverage (coverage_lexer.go), for the benchmarks and the tests of the
+// generated code. Run go generate
+// after changing the templates or the generator.
+//
+package sample
+
+//go:generate go run ../../cmd/piglex generate -l regexp.pigl
+//go:generate go run ../../cmd/piglex generate -l dfa.pigl
+//go:generate go run ../../cmd/piglex generate -coverage -l coverage.pigl
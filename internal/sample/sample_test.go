@@ -0,0 +1,123 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package sample
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/peergum/piglex"
+)
+
+const (
+	BENCH_SIZE = 4 << 20 // bytes of BASIC source for the benchmarks
+)
+
+var benchInput []byte
+
+//
+// basicSource generates a BASIC source of at least size bytes,
+// using every rule of sample.pigl
+//
+func basicSource(size int) []byte {
+	var buf bytes.Buffer
+	for i := 0; buf.Len() < size; i++ {
+		fmt.Fprintf(&buf, "LABEL l%d\n\tPRINT \"line %d, 10%% done\"\n\tINPUT v%d\n\tGOTO l%d\n", i, i, i, i/2)
+	}
+	return buf.Bytes()
+}
+
+func BenchmarkLexerRegexp(b *testing.B) {
+	benchmarkLexer(b, func(source io.Reader) func() error {
+		lexer := RegexpNewLexer(source)
+		return func() error {
+			_, err := lexer.Next()
+			return err
+		}
+	})
+}
+
+func BenchmarkLexerDFA(b *testing.B) {
+	benchmarkLexer(b, func(source io.Reader) func() error {
+		lexer := DfaNewLexer(source)
+		return func() error {
+			_, err := lexer.Next()
+			return err
+		}
+	})
+}
+
+//
+// benchmarkLexer lexes the whole benchmark input each time, with
+// the next function of a new lexer
+//
+func benchmarkLexer(b *testing.B, newLexer func(io.Reader) func() error) {
+	if benchInput == nil {
+		benchInput = basicSource(BENCH_SIZE)
+	}
+	b.SetBytes(int64(len(benchInput)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		next := newLexer(bytes.NewReader(benchInput))
+		for {
+			err := next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+//
+// TestGenerated checks the lexers of the package are those piglex
+// generates now
+//
+func TestGenerated(t *testing.T) {
+	tests := []struct {
+		file    string
+		options piglex.Options
+	}{
+		{"regexp.pigl", piglex.Options{}},
+		{"dfa.pigl", piglex.Options{}},
+		{"coverage.pigl", piglex.Options{Coverage: true}},
+	}
+	for _, test := range tests {
+		spec, err := piglex.ParseFile(test.file, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		source, err := piglex.Generate(spec, test.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		current, err := ioutil.ReadFile(spec.Output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(source, current) {
+			t.Errorf("%s is out of date, run go generate", spec.Output)
+		}
+	}
+}
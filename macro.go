@@ -0,0 +1,128 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package piglex
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+//
+// A macro action name(params) calls the Go function macro_name, which
+// must be defined in a source included with %include. It gets the
+// parameters in order: token (int), value (string), 'c' (rune), and
+// returns nothing or an error.
+//
+
+//
+// macroFunc is the signature of a macro function
+//
+type macroFunc struct {
+	params       int
+	returnsError bool
+}
+
+func macroName(name string) string {
+	return "macro_" + name
+}
+
+//
+// findMacros looks for the macro functions in the included Go sources
+//
+func findMacros(spec *Spec) (map[string]*macroFunc, error) {
+	macros := map[string]*macroFunc{}
+	for _, include := range spec.Includes {
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, include.File, include.Code, 0)
+		if err != nil {
+			return nil, fmt.Errorf("include file %q: %s", include.Name, err)
+		}
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv != nil || !strings.HasPrefix(fn.Name.Name, "macro_") {
+				continue
+			}
+			macro := &macroFunc{
+				params: fn.Type.Params.NumFields(),
+			}
+			if results := fn.Type.Results; results != nil && results.NumFields() == 1 {
+				if ident, ok := results.List[0].Type.(*ast.Ident); ok && ident.Name == "error" {
+					macro.returnsError = true
+				}
+			}
+			macros[strings.TrimPrefix(fn.Name.Name, "macro_")] = macro
+		}
+	}
+	return macros, nil
+}
+
+//
+// checkMacros reports the macro calls that don't match a macro function
+//
+func checkMacros(spec *Spec, macros map[string]*macroFunc) error {
+	diags := ErrorList{}
+	for _, rule := range spec.Rules {
+		for _, action := range rule.Actions {
+			if action.Kind != ACTION_MACRO {
+				continue
+			}
+			macro, ok := macros[action.Name]
+			switch {
+			case !ok:
+				diags.Add(action.Pos, SEVERITY_ERROR, "%s not found in included sources", macroName(action.Name))
+			case macro.params != len(action.Params):
+				diags.Add(action.Pos, SEVERITY_ERROR, "%s takes %d parameter(s), called with %d",
+					macroName(action.Name), macro.params, len(action.Params))
+			}
+		}
+	}
+	diags.setExcerpts(spec.sources)
+	return diags.Err()
+}
+
+//
+// writeMacroCall writes the call of a macro function
+//
+func writeMacroCall(buf *bytes.Buffer, rule *LexRule, action *Action, macro *macroFunc) {
+	args := make([]string, len(action.Params))
+	for i, param := range action.Params {
+		switch param.Kind {
+		case PARAM_TOKEN:
+			args[i] = "0"
+			if ret := rule.Return(); ret != nil {
+				args[i] = "int(" + tokenValue(ret) + ")"
+			}
+		case PARAM_VALUE:
+			args[i] = "token.Text"
+		case PARAM_CHAR:
+			args[i] = strconv.QuoteRune(param.Char)
+		}
+	}
+	call := macroName(action.Name) + "(" + strings.Join(args, ", ") + ")"
+	if macro.returnsError {
+		fmt.Fprintf(buf, "if err := %s; err != nil {\nreturn err\n}\n", call)
+	} else {
+		fmt.Fprintf(buf, "%s\n", call)
+	}
+}
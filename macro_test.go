@@ -0,0 +1,294 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package piglex
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+//
+// macroCalls parses a spec, returning its macro calls as
+// "pos name(param@col, ...)" and the diagnostics
+//
+func macroCalls(t *testing.T, source string) ([]string, []string) {
+	spec, err := ParseReader(strings.NewReader(source), &ParseOptions{Name: "t.pigl"})
+	diags := []string{}
+	if list, ok := err.(ErrorList); ok {
+		for _, diag := range list {
+			diags = append(diags, diag.Error())
+		}
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	calls := []string{}
+	for _, rule := range spec.Rules {
+		for _, action := range rule.Actions {
+			if action.Kind != ACTION_MACRO {
+				continue
+			}
+			params := []string{}
+			for _, param := range action.Params {
+				name := ""
+				switch param.Kind {
+				case PARAM_TOKEN:
+					name = "token"
+				case PARAM_VALUE:
+					name = "value"
+				case PARAM_CHAR:
+					name = strconv.QuoteRune(param.Char)
+				}
+				params = append(params, name+"@"+strconv.Itoa(param.Pos.Col))
+			}
+			calls = append(calls, action.Pos.String()+" "+action.Name+"("+strings.Join(params, ", ")+")")
+		}
+	}
+	return calls, diags
+}
+
+func TestMacroParams(t *testing.T) {
+	tests := []struct {
+		rule  string
+		calls []string
+		diags []string
+	}{
+		{
+			"x\tf()",
+			[]string{"t.pigl:3:3 f()"},
+			nil,
+		},
+		{
+			"x\tf(token, value, 'c')",
+			[]string{"t.pigl:3:3 f(token@5, value@12, 'c'@19)"},
+			nil,
+		},
+		{
+			"x\tf( value ,token )",
+			[]string{"t.pigl:3:3 f(value@6, token@13)"},
+			nil,
+		},
+		{
+			"x\tf(',', ')', '\\'', '\\n') return NUM",
+			[]string{`t.pigl:3:3 f(','@5, ')'@10, '\''@15, '\n'@21)`},
+			nil,
+		},
+		{
+			"x\tf(value) g(token)",
+			[]string{"t.pigl:3:3 f(value@5)", "t.pigl:3:12 g(token@14)"},
+			nil,
+		},
+		{
+			"x\tf(foo, , 'ab', '\\q')",
+			[]string{"t.pigl:3:3 f()"},
+			[]string{
+				`t.pigl:3:5: macro f: unknown parameter "foo", expecting token, value or 'c'`,
+				"t.pigl:3:10: macro f: missing parameter",
+				"t.pigl:3:12: macro f: invalid character 'ab'",
+				`t.pigl:3:18: macro f: invalid character '\q'`,
+			},
+		},
+		{
+			"x\tf(value,)",
+			[]string{"t.pigl:3:3 f(value@5)"},
+			[]string{"t.pigl:3:11: macro f: missing parameter"},
+		},
+		{
+			"x\tf(value",
+			[]string{"t.pigl:3:3 f()"},
+			[]string{"t.pigl:3:3: unterminated call to macro f"},
+		},
+	}
+	for _, test := range tests {
+		calls, diags := macroCalls(t, "%token NUM\n%lex\n"+test.rule+"\n")
+		if strings.Join(calls, "\n") != strings.Join(test.calls, "\n") {
+			t.Errorf("%q: calls %q, want %q", test.rule, calls, test.calls)
+		}
+		if strings.Join(diags, "\n") != strings.Join(test.diags, "\n") {
+			t.Errorf("%q: diagnostics %q, want %q", test.rule, diags, test.diags)
+		}
+	}
+}
+
+// Go source defining the macros of the tests
+const macroCode = `package main
+
+func macro_count(token int, value string) {}
+
+func macro_check(value string, c rune) error { return nil }
+
+func (l *Lexer) macro_method() {}
+`
+
+func TestCheckMacros(t *testing.T) {
+	tests := []struct {
+		rule string
+		err  string
+	}{
+		{"x\tcount(token, value) return NUM", ""},
+		{"x\tcheck(value, 'x')", ""},
+		{"x\tmissing(value)", "t.pigl:3:3: macro_missing not found in included sources"},
+		{"x\tmethod()", "t.pigl:3:3: macro_method not found in included sources"},
+		{"x\tcount(value)", "t.pigl:3:3: macro_count takes 2 parameter(s), called with 1"},
+		{"x\treturn NUM check(value, 'x', token)", "t.pigl:3:14: macro_check takes 2 parameter(s), called with 3"},
+	}
+	for _, test := range tests {
+		spec, err := ParseReader(strings.NewReader("%token NUM\n%lex\n"+test.rule+"\n"), &ParseOptions{Name: "t.pigl"})
+		if err != nil {
+			t.Fatalf("%q: %s", test.rule, err)
+		}
+		spec.Includes = append(spec.Includes, &Include{Name: "macros.go", File: "macros.go", Code: macroCode})
+		macros, err := findMacros(spec)
+		if err != nil {
+			t.Fatalf("%q: %s", test.rule, err)
+		}
+		err = checkMacros(spec, macros)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != test.err {
+			t.Errorf("%q: got %q, want %q", test.rule, got, test.err)
+		}
+	}
+}
+
+func TestWriteMacroCall(t *testing.T) {
+	tests := []struct {
+		rule  string
+		macro *macroFunc
+		want  string
+	}{
+		{
+			"x\tcount(token, value) return NUM",
+			&macroFunc{params: 2},
+			"macro_count(int(TOKEN_NUM), token.Text)\n",
+		},
+		{
+			"x\tcount(token, value)",
+			&macroFunc{params: 2},
+			"macro_count(0, token.Text)\n",
+		},
+		{
+			"x\tcheck(value, '\\'') return NUM",
+			&macroFunc{params: 2, returnsError: true},
+			"if err := macro_check(token.Text, '\\''); err != nil {\nreturn err\n}\n",
+		},
+		{
+			"x\tnone()",
+			&macroFunc{returnsError: true},
+			"if err := macro_none(); err != nil {\nreturn err\n}\n",
+		},
+	}
+	for _, test := range tests {
+		spec, err := ParseReader(strings.NewReader("%token NUM\n%lex\n"+test.rule+"\n"), &ParseOptions{Name: "t.pigl"})
+		if err != nil {
+			t.Fatalf("%q: %s", test.rule, err)
+		}
+		rule := spec.Rules[0]
+		buf := &bytes.Buffer{}
+		writeMacroCall(buf, rule, rule.Actions[0], test.macro)
+		if got := buf.String(); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.rule, got, test.want)
+		}
+	}
+}
+
+func TestLexerMacros(t *testing.T) {
+	source := "%token NUM, WORD\n%lex\n" +
+		"[0-9]+\tcount(token, value, '#') return NUM\n" +
+		"[a-z]+\tcheck(value)\n" +
+		"[A-Z]+\tmissing()\n" +
+		"[ ]+\t\n"
+	spec, err := ParseReader(strings.NewReader(source), &ParseOptions{Name: "t.pigl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	failure := errors.New("no words")
+	calls := []string{}
+	lex := func(input string) ([]string, error) {
+		lexer, err := NewLexer(spec, strings.NewReader(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lexer.Macros["count"] = func(lexer *Lexer, text string, args ...interface{}) error {
+			calls = append(calls, fmt.Sprintf("count %q %#v", text, args))
+			return nil
+		}
+		lexer.Macros["check"] = func(lexer *Lexer, text string, args ...interface{}) error {
+			calls = append(calls, fmt.Sprintf("check %q %#v", text, args))
+			return failure
+		}
+		calls = calls[:0]
+		names := []string{}
+		for {
+			token, err := lexer.Next()
+			if err == io.EOF {
+				return names, nil
+			}
+			if err != nil {
+				return names, err
+			}
+			names = append(names, token.Name)
+		}
+	}
+
+	names, err := lex("12 34")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`count "12" []interface {}{256, "12", 35}`,
+		`count "34" []interface {}{256, "34", 35}`,
+	}
+	if strings.Join(names, " ") != "NUM NUM" || strings.Join(calls, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got tokens %q and calls %q, want NUM NUM and %q", names, calls, want)
+	}
+
+	names, err = lex("12 abc 34")
+	if err != failure {
+		t.Errorf("got error %v, want %v", err, failure)
+	}
+	want = []string{
+		`count "12" []interface {}{256, "12", 35}`,
+		`check "abc" []interface {}{"abc"}`,
+	}
+	if strings.Join(names, " ") != "NUM" || strings.Join(calls, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got tokens %q and calls %q, want NUM and %q", names, calls, want)
+	}
+
+	_, err = lex("ABC")
+	if err == nil || err.Error() != "t.pigl:5:8: no macro missing" {
+		t.Errorf("got error %v, want no macro missing", err)
+	}
+
+	lexer, err := NewLexer(spec, strings.NewReader("abc ABC 1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lexer.IgnoreMacros()
+	token, err := lexer.Next()
+	if err != nil || token.Name != "NUM" || token.Text != "1" {
+		t.Errorf("with IgnoreMacros: got %v %v, want NUM \"1\"", token, err)
+	}
+}
@@ -0,0 +1,84 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package piglex
+
+import (
+	"strings"
+	"testing"
+)
+
+//
+// The rule matching the longest prefix wins, the first rule when
+// several match it, with the regexp matchers of the runtime (as in
+// templates/lexer.go) and with the DFA.
+//
+
+var tieTests = []struct {
+	patterns []string
+	input    string
+	rule     int
+	size     int
+}{
+	{[]string{`if`, `[a-z]+`}, "if(", 0, 2},
+	{[]string{`if`, `[a-z]+`}, "ifx", 1, 3},
+	{[]string{`[a-z]+`, `if`}, "if", 0, 2},
+	{[]string{`a`, `ab`, `a|ab`}, "ab", 1, 2},
+	{[]string{`a|ab`, `ab`}, "ab", 0, 2},
+	{[]string{`ab`, `a|ab`}, "abc", 0, 2},
+	{[]string{`x*`, `x+y?`}, "xxx", 0, 3},
+	{[]string{`x*`, `x+y?`}, "xxy", 1, 3},
+	{[]string{`(?i)print`, `PRINT`}, "PRINT 1", 0, 5},
+	{[]string{`"`, `"[^"]*"`, `[^"]+`}, `"a" b`, 1, 3},
+	{[]string{`"`, `"[^"]*"`, `[^"]+`}, `"a b`, 0, 1},
+}
+
+func TestRuleMatcherTies(t *testing.T) {
+	for _, test := range tieTests {
+		rules := make([]*LexRule, len(test.patterns))
+		for i, pattern := range test.patterns {
+			rules[i] = &LexRule{Pattern: pattern}
+		}
+		m, err := newRuleMatcher(rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rule, size := m.match(&input{source: strings.NewReader(test.input)})
+		index := -1
+		for i := range rules {
+			if rules[i] == rule {
+				index = i
+			}
+		}
+		if index != test.rule || size != test.size {
+			t.Errorf("%q on %q: got rule %d size %d, want rule %d size %d", test.patterns, test.input, index, size, test.rule, test.size)
+		}
+	}
+}
+
+func TestDFATies(t *testing.T) {
+	for _, test := range tieTests {
+		d, err := newDFA(test.patterns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rule, size := d.longest(test.input); rule != test.rule || size != test.size {
+			t.Errorf("%q on %q: got rule %d size %d, want rule %d size %d", test.patterns, test.input, rule, size, test.rule, test.size)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package piglex
+
+import (
+	"regexp"
+	"strings"
+)
+
+var goIdent = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+//
+// option handles %option name[=value][, ...]
+//
+func (lex *specLexer) option(directive *Directive) {
+	if len(directive.Args) == 0 {
+		lex.errorf(directive.Pos, "%%option: missing option")
+	}
+	for i, arg := range directive.Args {
+		pos := directive.ArgPos(i)
+		name, value := arg, ""
+		if eq := strings.IndexRune(arg, '='); eq >= 0 {
+			name, value = arg[:eq], arg[eq+1:]
+		}
+		switch name {
+		case "caseless", "wrap", "debug", "unicode", "nounicode":
+			if value != "" {
+				lex.errorf(pos, "%%option: %s takes no value", name)
+				continue
+			}
+		case "package", "prefix", "backend":
+			if value == "" {
+				lex.errorf(pos, "%%option: %s needs a value (%s=...)", name, name)
+				continue
+			}
+		}
+		switch name {
+		case "caseless":
+			lex.spec.Caseless = true
+		case "wrap":
+			lex.spec.Wrap = true
+		case "debug":
+			lex.spec.Debug = true
+		case "unicode":
+			lex.spec.NoUnicode = false
+		case "nounicode":
+			lex.spec.NoUnicode = true
+		case "package":
+			if !goIdent.MatchString(value) {
+				lex.errorf(pos, "%%option: invalid package name %q", value)
+				continue
+			}
+			lex.spec.Package = value
+		case "prefix":
+			if !goIdent.MatchString(value) {
+				lex.errorf(pos, "%%option: invalid prefix %q", value)
+				continue
+			}
+			lex.spec.Prefix = value
+		case "backend":
+			if value != BACKEND_REGEXP && value != BACKEND_DFA {
+				lex.errorf(pos, "%%option: unknown backend %q", value)
+				continue
+			}
+			lex.spec.Backend = value
+		default:
+			lex.errorf(pos, "%%option: unknown option %q", name)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package piglex
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestOption(t *testing.T) {
+	tests := []struct {
+		options string
+		spec    string // the options set, as "package prefix backend caseless wrap debug nounicode"
+		diags   []string
+	}{
+		{"caseless, wrap, debug", "main  regexp true true true false", nil},
+		{"nounicode, backend=dfa", "main  dfa false false false true", nil},
+		{"nounicode, unicode", "main  regexp false false false false", nil},
+		{"package=lexer, prefix=basic", "lexer basic regexp false false false false", nil},
+		{"caseless=yes", "main  regexp false false false false",
+			[]string{"t.pigl:1:9: %option: caseless takes no value"}},
+		{"wrap, debug=1, prefix=ok", "main ok regexp false true false false",
+			[]string{"t.pigl:1:15: %option: debug takes no value"}},
+		{"package", "main  regexp false false false false",
+			[]string{"t.pigl:1:9: %option: package needs a value (package=...)"}},
+		{"prefix=", "main  regexp false false false false",
+			[]string{"t.pigl:1:9: %option: prefix needs a value (prefix=...)"}},
+		{"package=my-lexer", "main  regexp false false false false",
+			[]string{`t.pigl:1:9: %option: invalid package name "my-lexer"`}},
+		{"prefix=1x", "main  regexp false false false false",
+			[]string{`t.pigl:1:9: %option: invalid prefix "1x"`}},
+		{"backend=nfa", "main  regexp false false false false",
+			[]string{`t.pigl:1:9: %option: unknown backend "nfa"`}},
+		{"bogus", "main  regexp false false false false",
+			[]string{`t.pigl:1:9: %option: unknown option "bogus"`}},
+		{"", "main  regexp false false false false",
+			[]string{"t.pigl:1:1: %option: missing option"}},
+	}
+	for _, test := range tests {
+		source := "%option " + test.options + "\n%token A\n%lex\na\treturn A\n"
+		spec, err := ParseReader(strings.NewReader(source), &ParseOptions{Name: "t.pigl"})
+		diags := []string{}
+		if list, ok := err.(ErrorList); ok {
+			for _, diag := range list {
+				diags = append(diags, diag.Error())
+			}
+		} else if err != nil {
+			t.Fatalf("%q: %s", test.options, err)
+		}
+		if strings.Join(diags, "\n") != strings.Join(test.diags, "\n") {
+			t.Errorf("%q: diagnostics %q, want %q", test.options, diags, test.diags)
+		}
+		options := &Options{}
+		got := fmt.Sprintf("%s %s %s %t %t %t %t", packageName(spec), spec.Prefix, options.backend(spec),
+			spec.Caseless, spec.Wrap, spec.Debug, spec.NoUnicode)
+		if got != test.spec {
+			t.Errorf("%q: got %q, want %q", test.options, got, test.spec)
+		}
+	}
+}
+
+func TestNoUnicodeBackend(t *testing.T) {
+	tests := []struct {
+		options string
+		backend string
+		err     string
+	}{
+		{"nounicode", "", "%option nounicode needs the dfa backend"},
+		{"nounicode", BACKEND_REGEXP, "%option nounicode needs the dfa backend"},
+		{"nounicode", BACKEND_DFA, ""},
+		{"nounicode, backend=dfa", "", ""},
+		{"nounicode, backend=dfa", BACKEND_REGEXP, "%option nounicode needs the dfa backend"},
+	}
+	for _, test := range tests {
+		source := "%option " + test.options + "\n%token A\n%lex\na\treturn A\n"
+		spec, err := ParseReader(strings.NewReader(source), &ParseOptions{Name: "t.pigl"})
+		if err != nil {
+			t.Fatalf("%q: %s", test.options, err)
+		}
+		_, err = Generate(spec, Options{Backend: test.backend})
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != test.err {
+			t.Errorf("%q with backend %q: got %q, want %q", test.options, test.backend, got, test.err)
+		}
+	}
+}
+
+func TestPrefixName(t *testing.T) {
+	tests := []struct {
+		prefix string
+		name   string
+		want   string
+	}{
+		{"basic", "Lexer", "BasicLexer"},
+		{"basic", "rules", "basicRules"},
+		{"Basic", "rules", "basicRules"},
+		{"Basic", "NewLexer", "BasicNewLexer"},
+		{"dfa", "TOKEN_NUM", "DfaTOKEN_NUM"},
+		{"dfa", "_x", "dfa_x"},
+		{"x", "r", "xR"},
+		{"éclair", "lexer", "éclairLexer"},
+		{"éclair", "Lexer", "ÉclairLexer"},
+	}
+	for _, test := range tests {
+		if got := prefixName(test.prefix, test.name); got != test.want {
+			t.Errorf("prefixName(%q, %q) = %q, want %q", test.prefix, test.name, got, test.want)
+		}
+	}
+}
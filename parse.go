@@ -0,0 +1,1440 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package piglex
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	BLANKSPACES = " \t"
+)
+
+// PigYacc directives, skipped outside of %yacc sections
+var yaccDirectives = map[string]bool{
+	"type":     true,
+	"left":     true,
+	"right":    true,
+	"nonassoc": true,
+	"start":    true,
+	"union":    true,
+	"expect":   true,
+}
+
+const (
+	STATE_INIT = iota
+	STATE_FINISHED
+	STATE_ERROR
+	STATE_STACK
+	STATE_SLASH
+	STATE_STAR
+	STATE_LINECOMMENT
+	STATE_CCOMMENT
+	STATE_PERCENT
+	STATE_LEXRULES
+	STATE_ACTION
+	STATE_ACTIONBLOCK
+	STATE_ACTIONEND
+	STATE_MACRO
+	STATE_YACC
+
+	USER_STATE
+)
+
+const (
+	TOKEN_DOUBLESLASH = 256 + iota
+	TOKEN_SLASHSTAR
+	TOKEN_STARSLASH
+	TOKEN_PERCENT
+	TOKEN_COMMENTLINE
+	TOKEN_INSTRUCTION
+	TOKEN_EOF
+	TOKEN_REGEXP
+	TOKEN_BLOCKSTART
+	TOKEN_ACTIONBLOCK
+	TOKEN_ACTION
+	TOKEN_BLOCKEND
+	TOKEN_RETURN
+	TOKEN_STATE
+	TOKEN_TOKEN
+	TOKEN_LEN
+	TOKEN_VALUE
+	TOKEN_ERROR
+	TOKEN_MACRO
+
+	USER_TOKEN
+)
+
+const (
+	CMD_LEX = iota
+	CMD_INIT
+	CMD_TOKEN
+	CMD_STATE
+)
+
+var keywords = map[string]int{
+	"return": TOKEN_RETURN,
+	"state":  TOKEN_STATE,
+	"token":  TOKEN_TOKEN,
+	"len":    TOKEN_LEN,
+	"value":  TOKEN_VALUE,
+	"error":  TOKEN_ERROR,
+}
+
+type lexToken struct {
+	id    int
+	char  rune
+	value interface{}
+	pos   Pos
+}
+
+type lexState struct {
+	current int
+	token   *lexToken
+}
+
+type specLexer struct {
+	source   *bufio.Reader
+	states   []*lexState
+	tokens   []*lexToken
+	position int
+	spec     *Spec
+	scope    *Scope
+	rule     *LexRule
+	action   *Action
+	macro    *Action
+	yacc     bool // in a %yacc section
+	quote    bool
+	escape   bool
+	file     string
+	chain    []string
+	paths    []string
+	cursor   cursor
+	last     cursor
+	lastChar rune
+	blockPos Pos
+	errors   *ErrorList
+}
+
+//
+// cursor tracks the line and column of the last character read
+//
+type cursor struct {
+	line int
+	col  int
+	eol  bool
+	text string
+}
+
+//
+// parseSpec runs the PigLex state machine over a .pigl source
+// and returns the resulting spec. Errors in the spec are returned
+// as an ErrorList, along with the (partial) spec.
+//
+func parseSpec(file string, reader io.Reader, paths []string, caseless bool) (*Spec, error) {
+	diags := ErrorList{}
+	lex := newSpecLexer(newSpec(file), reader, file)
+	lex.spec.Caseless = caseless
+	if abs, err := filepath.Abs(file); err == nil {
+		lex.chain = []string{abs}
+	}
+	lex.paths = paths
+	lex.errors = &diags
+	if err := lex.run(); err != nil {
+		return nil, err
+	}
+	lex.spec.resolveScopes()
+	lex.spec.expandDefs(&diags)
+	diags.setExcerpts(lex.spec.sources)
+	diags.Sort()
+	return lex.spec, diags.Err()
+}
+
+//
+// run goes through the states until the end of the source
+//
+func (lex *specLexer) run() error {
+	for !lex.finished() {
+		if err := lex.nextState(); err != nil {
+			if err != io.EOF {
+				return err
+			}
+			break
+		}
+	}
+	lex.flush()
+	return nil
+}
+
+func newSpecLexer(spec *Spec, reader io.Reader, file string) *specLexer {
+	return &specLexer{
+		source: bufio.NewReader(reader),
+		states: []*lexState{
+			{
+				current: STATE_INIT,
+				token: &lexToken{
+					id:    0,
+					char:  0,
+					value: "",
+				},
+			},
+		},
+		tokens:   make([]*lexToken, 0, 100),
+		position: -1,
+		spec:     spec,
+		scope:    globalScope,
+		file:     file,
+		cursor:   cursor{line: 1},
+		errors:   &ErrorList{},
+	}
+}
+
+//
+// getNewState handles current state
+//
+func (lex *specLexer) nextState() error {
+	switch lex.getState().current {
+	case STATE_INIT:
+		return lex.stateInit()
+	case STATE_FINISHED:
+	case STATE_STACK:
+	case STATE_ERROR:
+		return lex.stateError()
+	case STATE_SLASH:
+		return lex.stateSlash()
+	case STATE_CCOMMENT:
+		return lex.stateCComment()
+	case STATE_STAR:
+		return lex.stateStar()
+	case STATE_LINECOMMENT:
+		return lex.stateLineComment()
+	case STATE_PERCENT:
+		return lex.statePercent()
+	case STATE_LEXRULES:
+		return lex.stateLexRules()
+	case STATE_ACTION:
+		return lex.stateAction()
+	case STATE_ACTIONBLOCK:
+		return lex.stateActionBlock()
+	case STATE_ACTIONEND:
+		return lex.stateActionEnd()
+	case STATE_MACRO:
+		return lex.stateMacro()
+	case STATE_YACC:
+		return lex.stateYacc()
+	default:
+		return lex.stateError()
+	}
+
+	return nil
+}
+
+func (lex *specLexer) getState() *lexState {
+	currentState := len(lex.states) - 1
+	return lex.states[currentState]
+}
+
+func (lex *specLexer) pushState(state *lexState) error {
+	lex.states = append(lex.states, state)
+	//fmt.Printf("\nUp %d: %d\n", len(lex.states), state.current)
+	return nil
+}
+
+func (lex *specLexer) replaceState(state *lexState) error {
+	lex.popState()
+	lex.pushState(state)
+	return nil
+}
+
+func (lex *specLexer) popState() {
+	currentState := len(lex.states) - 1
+	lex.states = lex.states[:currentState]
+	//fmt.Printf("\nDown %d: %d\n-- ", len(lex.states), lex.getState().current)
+}
+
+func (lex *specLexer) getToken() *lexToken {
+	return lex.getState().token
+}
+
+func (lex *specLexer) replaceToken(token *lexToken) {
+	lex.getState().token = token
+}
+
+func (lex *specLexer) finished() bool {
+	return (lex.getState().current == STATE_FINISHED)
+}
+
+func (lex *specLexer) setErrorState(error) {
+	lex.getState().current = STATE_ERROR
+}
+
+func (lex *specLexer) printTokenValue() {
+	fmt.Println("Token:", lex.getState().token.value)
+}
+
+func (lex *specLexer) getNext() (c rune, err error) {
+	if c, _, err = lex.source.ReadRune(); err != nil {
+		lex.emit(&lexToken{
+			id:    TOKEN_EOF,
+			char:  0,
+			value: err.Error(),
+			pos:   lex.pos(),
+		})
+		return 0, err
+	}
+	lex.advance(c)
+	if c != '\n' {
+		lex.position++
+	}
+	return
+}
+
+//
+// advance moves the cursor over a character, keeping
+// the source lines for diagnostics
+//
+func (lex *specLexer) advance(c rune) {
+	lex.last = lex.cursor
+	lex.lastChar = c
+	if lex.cursor.eol {
+		lex.spec.setLine(lex.file, lex.cursor.line, lex.cursor.text)
+		lex.cursor = cursor{line: lex.cursor.line + 1}
+	}
+	lex.cursor.col++
+	switch c {
+	case '\n':
+		lex.cursor.eol = true
+	case '\r':
+	default:
+		lex.cursor.text += string(c)
+	}
+}
+
+//
+// unread pushes back the last character read
+//
+func (lex *specLexer) unread() {
+	if lex.source.UnreadRune() == nil {
+		if lex.lastChar != '\n' {
+			lex.position--
+		}
+		lex.cursor = lex.last
+	}
+}
+
+//
+// pos returns the position of the last character read
+//
+func (lex *specLexer) pos() Pos {
+	return Pos{
+		File: lex.file,
+		Line: lex.cursor.line,
+		Col:  lex.cursor.col,
+	}
+}
+
+//
+// tokenPos returns the position where the current token started
+//
+func (lex *specLexer) tokenPos() Pos {
+	if token := lex.getToken(); token.pos.Line > 0 {
+		return token.pos
+	}
+	return lex.pos()
+}
+
+//
+// errorf records an error at the given position
+//
+func (lex *specLexer) errorf(pos Pos, format string, v ...interface{}) {
+	lex.errors.Add(pos, SEVERITY_ERROR, format, v...)
+}
+
+func (lex *specLexer) emit(token *lexToken) {
+	lex.tokens = append(lex.tokens, token)
+	logMsg("Token: ", token.value)
+}
+
+//
+// flush completes whatever was pending when the source ended
+//
+func (lex *specLexer) flush() {
+	lex.spec.setLine(lex.file, lex.cursor.line, lex.cursor.text)
+	for _, state := range lex.states {
+		switch state.current {
+		case STATE_CCOMMENT:
+			lex.errorf(state.token.pos, "unterminated comment")
+		case STATE_ACTIONBLOCK:
+			lex.errorf(lex.blockPos, "unterminated action block")
+		case STATE_MACRO:
+			lex.errorf(lex.macro.Pos, "unterminated call to macro %s", lex.macro.Name)
+		}
+	}
+	switch lex.getState().current {
+	case STATE_ACTION:
+		lex.checkKeyword()
+	case STATE_PERCENT:
+		lex.checkCommand()
+	}
+	lex.endRule()
+}
+
+func (lex *specLexer) endRule() {
+	lex.checkAction()
+	lex.rule = nil
+	lex.action = nil
+}
+
+//
+// inChar tells if the action word is an unterminated character
+// literal ('+'), blanks, braces and parentheses being part of it
+//
+func (lex *specLexer) inChar() bool {
+	value := lex.getToken().value.(string)
+	if !strings.HasPrefix(value, "'") {
+		return false
+	}
+	closed := len(value) > 1 && strings.HasSuffix(value, "'") &&
+		(!strings.HasSuffix(value, `\'`) || strings.HasSuffix(value, `\\'`))
+	return !closed
+}
+
+func (lex *specLexer) addChar(c rune) {
+	token := &lexToken{
+		id:    0,
+		char:  c,
+		value: lex.getToken().value.(string) + string(c),
+		pos:   lex.tokenPos(),
+	}
+	lex.replaceToken(token)
+}
+
+func (lex *specLexer) checkKeyword() {
+	value := lex.getToken().value.(string)
+	pos := lex.getToken().pos
+	switch {
+	case len(value) == 0:
+	case lex.action != nil && lex.action.Name == "":
+		// name of the token or state, checked by validate()
+		token := &lexToken{
+			id:    USER_TOKEN,
+			char:  0,
+			value: value,
+			pos:   pos,
+		}
+		if lex.action.Kind == ACTION_STATE {
+			token.id = USER_STATE
+		}
+		lex.emit(token)
+		lex.action.Name = value
+		if lex.action.Kind == ACTION_RETURN && strings.HasPrefix(value, "'") {
+			// literal character token: return '+'
+			char, err := strconv.Unquote(value)
+			if err != nil || utf8.RuneCountInString(char) != 1 {
+				lex.errorf(pos, "invalid character token %s", value)
+			} else {
+				lex.action.Char, _ = utf8.DecodeRuneInString(char)
+			}
+		}
+		lex.action = nil
+	default:
+		tokenId, found := keywords[value]
+		if !found {
+			token := &lexToken{
+				id:    TOKEN_ERROR,
+				char:  0,
+				value: "ERR: " + value,
+				pos:   pos,
+			}
+			lex.emit(token)
+			lex.errorf(pos, "unknown action %q", value)
+			break
+		}
+		token := &lexToken{
+			id:    tokenId,
+			char:  0,
+			value: value,
+			pos:   pos,
+		}
+		lex.emit(token)
+		lex.addAction(tokenId, pos)
+	}
+	token := &lexToken{
+		id:    0,
+		char:  0,
+		value: "",
+	}
+	lex.replaceToken(token)
+}
+
+//
+// addAction starts a new action in the current rule
+//
+func (lex *specLexer) addAction(tokenId int, pos Pos) {
+	lex.checkAction()
+	lex.action = nil
+	if lex.rule == nil {
+		return
+	}
+	switch tokenId {
+	case TOKEN_RETURN:
+		lex.action = &Action{Kind: ACTION_RETURN, Pos: pos}
+	case TOKEN_STATE:
+		lex.action = &Action{Kind: ACTION_STATE, Pos: pos}
+	case TOKEN_MACRO:
+		lex.action = &Action{Kind: ACTION_MACRO, Name: lex.getToken().value.(string), Pos: pos}
+	default:
+		return
+	}
+	lex.rule.Actions = append(lex.rule.Actions, lex.action)
+}
+
+//
+// checkAction reports a pending action missing its name
+//
+func (lex *specLexer) checkAction() {
+	if lex.action != nil && lex.action.Name == "" {
+		switch lex.action.Kind {
+		case ACTION_RETURN:
+			lex.errorf(lex.action.Pos, "missing token after return")
+		case ACTION_STATE:
+			lex.errorf(lex.action.Pos, "missing state after state")
+		}
+		lex.action = nil
+	}
+}
+
+func (lex *specLexer) checkCommand() error {
+	value := lex.getToken().value.(string)
+	token := &lexToken{
+		id:    TOKEN_INSTRUCTION,
+		char:  0,
+		value: value,
+		pos:   lex.getToken().pos,
+	}
+	lex.emit(token)
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		lex.popState()
+		return nil
+	}
+	directive := &Directive{
+		Name: fields[0],
+		Pos:  token.pos,
+	}
+	if lex.yacc && directive.Name != "lex" {
+		// PigYacc section, up to the next %lex
+		lex.popState()
+		return nil
+	}
+	// value starts right after the %
+	start := strings.Index(value, fields[0]) + len(fields[0])
+	args, offsets := parseArgs(value[start:])
+	directive.Args = args
+	for _, offset := range offsets {
+		pos := directive.Pos
+		pos.Col += 1 + len([]rune(value[:start])) + offset
+		directive.argPos = append(directive.argPos, pos)
+	}
+	lex.spec.Directives = append(lex.spec.Directives, directive)
+	switch directive.Name {
+	case "lex", "yacc":
+		token = &lexToken{
+			id:    0,
+			char:  0,
+			value: "",
+		}
+		state := &lexState{
+			current: STATE_LEXRULES,
+			token:   token,
+		}
+		lex.yacc = directive.Name == "yacc"
+		if lex.yacc {
+			state.current = STATE_YACC
+		}
+		lex.popState()
+		lex.replaceState(state)
+		return nil
+	case "only", "except":
+		// an empty list makes the following rules global again
+		lex.scope = globalScope
+		if len(directive.Args) > 0 {
+			lex.scope = &Scope{
+				Mode:   SCOPE_ONLY,
+				States: directive.Args,
+				Pos:    directive.Pos,
+			}
+			if directive.Name == "except" {
+				lex.scope.Mode = SCOPE_EXCEPT
+			}
+		}
+		logMsg("Scope:", lex.scope)
+	case "include":
+		logMsg("Include file:", strings.Join(directive.Args, ", "))
+		for i, name := range directive.Args {
+			if err := lex.include(name, directive.ArgPos(i)); err != nil {
+				return err
+			}
+		}
+	case "output":
+		if len(directive.Args) > 0 {
+			lex.spec.Output = directive.Args[0]
+		}
+		logMsg("Output file (lexer):", strings.Join(directive.Args, ", "))
+	case "token":
+		for i, name := range directive.Args {
+			lex.spec.Tokens = append(lex.spec.Tokens, &Decl{Name: name, Pos: directive.ArgPos(i)})
+		}
+		logMsg("Token(s):", strings.Join(directive.Args, ", "))
+	case "state":
+		for i, name := range directive.Args {
+			lex.spec.States = append(lex.spec.States, &Decl{Name: name, Pos: directive.ArgPos(i)})
+		}
+		logMsg("State(s):", strings.Join(directive.Args, ", "))
+	case "define", "alias":
+		lex.define(directive, value[start:])
+	case "option":
+		lex.option(directive)
+	default:
+		if yaccDirectives[directive.Name] {
+			logMsg("PigYacc directive skipped:", directive.Name)
+			break
+		}
+		lex.errorf(directive.Pos, "unknown directive %%%s", directive.Name)
+	}
+	lex.popState()
+	return nil
+}
+
+func (lex *specLexer) checkComments(c rune) error {
+	switch {
+	case c == '/':
+		token := &lexToken{
+			id:    '/',
+			char:  '/',
+			value: string(c),
+			pos:   lex.pos(),
+		}
+		state := &lexState{
+			current: STATE_SLASH,
+			token:   token,
+		}
+		if lex.pushState(state) != nil {
+			return errors.New("Oops, can't push state!")
+		}
+	case c == '#':
+		token := &lexToken{
+			id:    '#',
+			char:  '#',
+			value: string(c),
+			pos:   lex.pos(),
+		}
+		state := &lexState{
+			current: STATE_LINECOMMENT,
+			token:   token,
+		}
+		if lex.pushState(state) != nil {
+			return errors.New("Oops, can't push state!")
+		}
+	}
+
+	return nil
+}
+
+//
+// Basic state
+//
+func (lex *specLexer) stateInit() error {
+	logMsg("=== INITIAL STATE ===")
+	for lex.getState().current == STATE_INIT {
+		c, err := lex.getNext()
+		if err != nil {
+			return err
+		}
+		lex.checkComments(c)
+		// check if we left init mode
+		if lex.getState().current != STATE_INIT {
+			break
+		}
+		switch {
+		case c == '%' && lex.position == 0:
+			token := &lexToken{
+				id:    0,
+				char:  0,
+				value: "",
+				pos:   lex.pos(),
+			}
+			state := &lexState{
+				current: STATE_PERCENT,
+				token:   token,
+			}
+			if lex.pushState(state) != nil {
+				return errors.New("Oops, can't push state!")
+			}
+			//lex.emit(token)
+		case c == '\r':
+		case c == '\n':
+			lex.position = -1
+		case strings.IndexRune(BLANKSPACES, c) >= 0:
+			// skip blanks
+		default:
+			token := &lexToken{
+				id:    0,
+				char:  c,
+				value: string(c),
+			}
+			lex.replaceToken(token)
+			lex.emit(token)
+		}
+	}
+	return nil
+}
+
+//
+// slash can be the beginning of a c-style comment or c++ comment line
+//
+func (lex *specLexer) stateSlash() error {
+	for lex.getState().current == STATE_SLASH {
+		c, err := lex.getNext()
+		if err != nil {
+			return err
+		}
+		switch {
+		case c == '/':
+			// line comment
+			token := &lexToken{
+				id:    TOKEN_DOUBLESLASH,
+				char:  0,
+				value: "//",
+				pos:   lex.getToken().pos,
+			}
+			state := &lexState{
+				current: STATE_LINECOMMENT,
+				token:   token,
+			}
+			lex.replaceState(state)
+			//lex.emit(token)
+		case c == '*':
+			// C style comment
+			token := &lexToken{
+				id:    TOKEN_SLASHSTAR,
+				char:  0,
+				value: "/*",
+				pos:   lex.getToken().pos,
+			}
+			state := &lexState{
+				current: STATE_CCOMMENT,
+				token:   token,
+			}
+			lex.replaceState(state)
+			//lex.emit(token)
+		default:
+			// not a comment: give the slash back to the previous state,
+			// and the character after it, even a blank
+			slash := lex.getToken()
+			lex.emit(slash)
+			lex.popState()
+			pos := slash.pos
+			if previous := lex.getToken(); previous.pos.Line > 0 {
+				pos = previous.pos
+			}
+			token := &lexToken{
+				id:    0,
+				char:  '/',
+				value: lex.getToken().value.(string) + "/",
+				pos:   pos,
+			}
+			lex.replaceToken(token)
+			lex.unread()
+		}
+	}
+	return nil
+}
+
+//
+// C-style comment... expecting */ to leave
+//
+func (lex *specLexer) stateCComment() error {
+	logMsg("=== C COMMENT ===")
+	for lex.getState().current == STATE_CCOMMENT {
+		c, err := lex.getNext()
+		if err != nil {
+			return err
+		}
+		token := &lexToken{
+			id:    0,
+			char:  c,
+			value: lex.getToken().value.(string) + string(c),
+			pos:   lex.tokenPos(),
+		}
+		switch c {
+		case '*':
+			state := &lexState{
+				current: STATE_STAR,
+				token:   token,
+			}
+			if lex.pushState(state) != nil {
+				return errors.New("Oops, can't push state!")
+			}
+		default:
+			lex.replaceToken(token)
+			//lex.emit(token)
+		}
+	}
+	return nil
+}
+
+//
+// we're waiting for a slash to leave the comment
+//
+func (lex *specLexer) stateStar() error {
+	for lex.getState().current == STATE_STAR {
+		c, err := lex.getNext()
+		if err != nil {
+			return err
+		}
+		switch c {
+		case '/':
+			// end of c-style comment
+			token := &lexToken{
+				id:    TOKEN_STARSLASH,
+				char:  0,
+				value: lex.getToken().value.(string) + "/",
+				pos:   lex.tokenPos(),
+			}
+			lex.popState()
+			lex.popState()
+			lex.emit(token)
+		case '*':
+			token := &lexToken{
+				id:    '*',
+				char:  '*',
+				value: lex.getToken().value.(string) + "*",
+				pos:   lex.tokenPos(),
+			}
+			//lex.emit(lex.getToken())
+			lex.replaceToken(token)
+		default:
+			lex.emit(lex.getToken())
+			token := &lexToken{
+				id:    0,
+				char:  c,
+				value: lex.getToken().value.(string) + string(c),
+				pos:   lex.tokenPos(),
+			}
+			lex.popState()
+			lex.replaceToken(token)
+			lex.emit(token)
+
+		}
+	}
+	return nil
+}
+
+//
+// we're waiting for the end of line
+//
+func (lex *specLexer) stateLineComment() error {
+	logMsg("=== INLINE COMMENT ===")
+	for lex.getState().current == STATE_LINECOMMENT {
+		c, err := lex.getNext()
+		if err != nil {
+			return err
+		}
+		switch c {
+		case '\r':
+			// do nothing (CR)
+		case '\n':
+			lex.position = -1
+			token := &lexToken{
+				id:    TOKEN_COMMENTLINE,
+				char:  0,
+				value: lex.getState().token.value,
+			}
+			lex.popState()
+			lex.emit(token)
+			//lex.replaceToken(token)
+			logMsg("Token: ", token.value)
+			// the end of the comment is the end of a directive line
+			if lex.getState().current == STATE_PERCENT {
+				if err := lex.checkCommand(); err != nil {
+					return err
+				}
+			}
+
+			//lex.printTokenValue()
+		default:
+			token := &lexToken{
+				id:    0,
+				char:  c,
+				value: lex.getToken().value.(string) + string(c),
+				pos:   lex.tokenPos(),
+			}
+			lex.replaceToken(token)
+			//lex.emit(token)
+		}
+	}
+	return nil
+}
+
+//
+// rawDirective tells if the rest of the directive line is taken as
+// is, without comments: the regexp of a %define or an %alias
+//
+func (lex *specLexer) rawDirective() bool {
+	value := strings.TrimLeft(lex.getToken().value.(string), BLANKSPACES)
+	end := strings.IndexAny(value, BLANKSPACES)
+	if end < 0 {
+		return false
+	}
+	switch value[:end] {
+	case "define", "alias":
+		return true
+	}
+	return false
+}
+
+//
+// instruction/command mode
+//
+func (lex *specLexer) statePercent() error {
+	logMsg("=== INSTRUCTION STATE ===")
+	for lex.getState().current == STATE_PERCENT {
+		c, err := lex.getNext()
+		if err != nil {
+			return err
+		}
+		if !lex.rawDirective() {
+			lex.checkComments(c)
+		}
+		// check if we left init mode
+		if lex.getState().current != STATE_PERCENT {
+			break
+		}
+		switch {
+		case c == '\r':
+		case c == '\n':
+			lex.position = -1
+			if err := lex.checkCommand(); err != nil {
+				return err
+			}
+		default:
+			token := &lexToken{
+				id:    0,
+				char:  c,
+				value: lex.getToken().value.(string) + string(c),
+				pos:   lex.tokenPos(),
+			}
+			lex.replaceToken(token)
+			//lex.emit(token)
+		}
+	}
+	return nil
+}
+
+//
+// PigYacc section: everything is skipped up to the next %lex
+//
+func (lex *specLexer) stateYacc() error {
+	logMsg("=== YACC STATE ===")
+	for lex.getState().current == STATE_YACC {
+		c, err := lex.getNext()
+		if err != nil {
+			return err
+		}
+		switch {
+		case c == '%' && lex.position == 0:
+			token := &lexToken{
+				id:    0,
+				char:  0,
+				value: "",
+				pos:   lex.pos(),
+			}
+			state := &lexState{
+				current: STATE_PERCENT,
+				token:   token,
+			}
+			lex.pushState(state)
+		case c == '\n':
+			lex.position = -1
+		}
+	}
+	return nil
+}
+
+//
+// regular expression
+//
+func (lex *specLexer) stateLexRules() error {
+	logMsg("=== LEX RULES STATE ===")
+	for lex.getState().current == STATE_LEXRULES {
+		c, err := lex.getNext()
+		if err != nil {
+			return err
+		}
+		// the regexp is taken as is up to the TAB, comments start
+		// at the beginning of a line
+		if lex.position == 0 {
+			lex.checkComments(c)
+		}
+		// check if we left init mode
+		if lex.getState().current != STATE_LEXRULES {
+			break
+		}
+		switch {
+		case c == '%' && lex.position == 0:
+			token := &lexToken{
+				id:    0,
+				char:  0,
+				value: "",
+				pos:   lex.pos(),
+			}
+			state := &lexState{
+				current: STATE_PERCENT,
+				token:   token,
+			}
+			lex.pushState(state)
+		case (c == '\t' || c == '\n') && lex.getToken().value.(string) != "":
+			token := &lexToken{
+				id:    TOKEN_REGEXP,
+				char:  c,
+				value: lex.getToken().value,
+				pos:   lex.getToken().pos,
+			}
+			lex.emit(token)
+			lex.rule = &LexRule{
+				Regexp: token.value.(string),
+				Scope:  lex.scope,
+				Pos:    token.pos,
+			}
+			lex.spec.Rules = append(lex.spec.Rules, lex.rule)
+			if c == '\n' {
+				lex.position = -1
+			}
+			token = &lexToken{
+				id:    0,
+				char:  0,
+				value: "",
+			}
+			state := &lexState{
+				current: STATE_ACTION,
+				token:   token,
+			}
+			lex.replaceState(state)
+		case c == '\r':
+		case c == '\n':
+			lex.position = -1
+			token := &lexToken{
+				id:    0,
+				char:  0,
+				value: "",
+			}
+			lex.replaceToken(token)
+			//lex.emit(token)
+		default:
+			token := &lexToken{
+				id:    0,
+				char:  c,
+				value: lex.getToken().value.(string) + string(c),
+				pos:   lex.tokenPos(),
+			}
+			lex.replaceToken(token)
+			//lex.emit(token)
+		}
+	}
+	return nil
+}
+
+//
+// action
+//
+func (lex *specLexer) stateAction() error {
+	logMsg("=== LEX ACTION STATE ===")
+	for lex.getState().current == STATE_ACTION {
+		c, err := lex.getNext()
+		if err != nil {
+			return err
+		}
+		if lex.position == 0 && strings.IndexRune(BLANKSPACES+"\r\n", c) < 0 {
+			// regexp alone on its line, and no indented action: next rule
+			lex.unread()
+			lex.endRule()
+			state := &lexState{
+				current: STATE_LEXRULES,
+				token: &lexToken{
+					id:    0,
+					char:  0,
+					value: "",
+				},
+			}
+			lex.replaceState(state)
+			break
+		}
+		// '#' and '/' don't start comments in a character token
+		if !lex.inChar() {
+			lex.checkComments(c)
+		}
+		// check if we left init mode
+		if lex.getState().current != STATE_ACTION {
+			break
+		}
+		switch {
+		case c != '\n' && lex.inChar():
+			lex.addChar(c)
+		case strings.IndexRune(BLANKSPACES, c) >= 0:
+			lex.checkKeyword()
+		case c == '\r':
+		case c == '(' && lex.getToken().value.(string) != "":
+			lex.startMacro()
+		case c == '{' && lex.position > 0:
+			lex.blockPos = lex.pos()
+			token := &lexToken{
+				id:    TOKEN_BLOCKSTART,
+				char:  c,
+				value: "{",
+				pos:   lex.blockPos,
+			}
+			lex.emit(token)
+			token = &lexToken{
+				id:    0,
+				char:  0,
+				value: "",
+			}
+			state := &lexState{
+				current: STATE_ACTIONBLOCK,
+				token:   token,
+			}
+			lex.replaceState(state)
+		case c == '\n' && lex.getToken().value.(string) != "":
+			lex.position = -1
+			lex.checkKeyword()
+			lex.endRule()
+			token := &lexToken{
+				id:    0,
+				char:  0,
+				value: "",
+			}
+			state := &lexState{
+				current: STATE_LEXRULES,
+				token:   token,
+			}
+			lex.replaceState(state)
+		case c == '\n':
+			lex.position = -1
+			lex.endRule()
+			token := &lexToken{
+				id:    0,
+				char:  0,
+				value: "",
+			}
+			state := &lexState{
+				current: STATE_LEXRULES,
+				token:   token,
+			}
+			lex.replaceState(state)
+		default:
+			token := &lexToken{
+				id:    0,
+				char:  c,
+				value: lex.getToken().value.(string) + string(c),
+				pos:   lex.tokenPos(),
+			}
+			lex.replaceToken(token)
+			//lex.emit(token)
+		}
+	}
+	return nil
+}
+
+//
+// action block
+//
+func (lex *specLexer) stateActionBlock() error {
+	logMsg("=== LEX ACTION BLOCK STATE ===")
+	for lex.getState().current == STATE_ACTIONBLOCK {
+		c, err := lex.getNext()
+		if err != nil {
+			return err
+		}
+		// '#' and '/' don't start comments in a character token
+		if !lex.inChar() {
+			lex.checkComments(c)
+		}
+		// check if we left init mode
+		if lex.getState().current != STATE_ACTIONBLOCK {
+			break
+		}
+		switch {
+		case c != '\n' && lex.inChar():
+			lex.addChar(c)
+		case strings.IndexRune(BLANKSPACES, c) >= 0 || c == '\n':
+			lex.checkKeyword()
+			if c == '\n' {
+				lex.position = -1
+			}
+		case c == '\r':
+		case c == '(' && lex.getToken().value.(string) != "":
+			lex.startMacro()
+		case c == '}':
+			lex.checkKeyword()
+			token := &lexToken{
+				id:    TOKEN_BLOCKEND,
+				char:  c,
+				value: "}",
+			}
+			lex.emit(token)
+			token = &lexToken{
+				id:    0,
+				char:  0,
+				value: "",
+			}
+			state := &lexState{
+				current: STATE_ACTIONEND,
+				token:   token,
+			}
+			lex.replaceState(state)
+		default:
+			token := &lexToken{
+				id:    0,
+				char:  c,
+				value: lex.getToken().value.(string) + string(c),
+				pos:   lex.tokenPos(),
+			}
+			lex.replaceToken(token)
+			//lex.emit(token)
+		}
+	}
+	return nil
+}
+
+func (lex *specLexer) stateActionEnd() error {
+	logMsg("=== LEX ACTION END STATE ===")
+	for lex.getState().current == STATE_ACTIONEND {
+		c, err := lex.getNext()
+		if err != nil {
+			return err
+		}
+		lex.checkComments(c)
+		// check if we left init mode
+		if lex.getState().current != STATE_ACTIONEND {
+			break
+		}
+		switch {
+		case strings.IndexRune(BLANKSPACES, c) >= 0:
+		case c == '\r':
+		case c == '\n':
+			lex.position = -1
+			lex.endRule()
+			token := &lexToken{
+				id:    0,
+				char:  0,
+				value: "",
+			}
+			state := &lexState{
+				current: STATE_LEXRULES,
+				token:   token,
+			}
+			lex.replaceState(state)
+		default:
+		}
+	}
+	return nil
+}
+
+//
+// startMacro starts the parameter list of a macro call, the name
+// being the word read so far
+//
+func (lex *specLexer) startMacro() {
+	name := lex.getToken().value.(string)
+	pos := lex.getToken().pos
+	lex.addAction(TOKEN_MACRO, pos)
+	lex.macro = lex.action
+	if lex.macro == nil {
+		// not in a rule, kept for the error messages
+		lex.macro = &Action{Kind: ACTION_MACRO, Name: name, Pos: pos}
+	}
+	lex.action = nil
+	lex.quote = false
+	lex.escape = false
+	lex.replaceToken(&lexToken{
+		id:    0,
+		char:  0,
+		value: "",
+	})
+	state := &lexState{
+		current: STATE_MACRO,
+		token: &lexToken{
+			id:    0,
+			char:  '(',
+			value: "",
+			pos:   lex.pos(),
+		},
+	}
+	lex.pushState(state)
+}
+
+//
+// macro parameters, up to the closing parenthesis
+//
+func (lex *specLexer) stateMacro() error {
+	logMsg("=== LEX MACRO STATE ===")
+	for lex.getState().current == STATE_MACRO {
+		c, err := lex.getNext()
+		if err != nil {
+			return err
+		}
+		switch {
+		case c == '\n':
+			lex.errorf(lex.macro.Pos, "unterminated call to macro %s", lex.macro.Name)
+			lex.popState()
+			lex.unread()
+			lex.macro = nil
+		case c == ')' && !lex.quote:
+			lex.endMacro()
+		default:
+			switch {
+			case lex.escape:
+				lex.escape = false
+			case c == '\\' && lex.quote:
+				lex.escape = true
+			case c == '\'':
+				lex.quote = !lex.quote
+			}
+			token := &lexToken{
+				id:    0,
+				char:  c,
+				value: lex.getToken().value.(string) + string(c),
+				pos:   lex.getToken().pos,
+			}
+			lex.replaceToken(token)
+		}
+	}
+	return nil
+}
+
+//
+// endMacro checks the parameters of a macro call and adds it
+// to the current rule
+//
+func (lex *specLexer) endMacro() {
+	value := lex.getToken().value.(string)
+	start := lex.getToken().pos
+	lex.popState()
+	macro := lex.macro
+	lex.macro = nil
+	lex.emit(&lexToken{
+		id:    TOKEN_MACRO,
+		char:  0,
+		value: macro.Name + "(" + value + ")",
+		pos:   macro.Pos,
+	})
+
+	args, offsets := splitParams(value)
+	for i, arg := range args {
+		pos := start
+		pos.Col += 1 + offsets[i]
+		param := &Param{Pos: pos}
+		switch {
+		case arg == "token":
+			param.Kind = PARAM_TOKEN
+		case arg == "value":
+			param.Kind = PARAM_VALUE
+		case strings.HasPrefix(arg, "'"):
+			char, err := strconv.Unquote(arg)
+			if err != nil || utf8.RuneCountInString(char) != 1 {
+				lex.errorf(pos, "macro %s: invalid character %s", macro.Name, arg)
+				continue
+			}
+			param.Kind = PARAM_CHAR
+			param.Char, _ = utf8.DecodeRuneInString(char)
+		case arg == "":
+			lex.errorf(pos, "macro %s: missing parameter", macro.Name)
+			continue
+		default:
+			lex.errorf(pos, "macro %s: unknown parameter %q, expecting token, value or 'c'", macro.Name, arg)
+			continue
+		}
+		macro.Params = append(macro.Params, param)
+	}
+}
+
+//
+// splitParams splits macro parameters on commas outside quotes,
+// trimming blanks. It also returns the offset (in runes) of each
+// parameter in the value.
+//
+func splitParams(value string) ([]string, []int) {
+	params := []string{}
+	offsets := []int{}
+	if strings.TrimSpace(value) == "" {
+		return params, offsets
+	}
+	current, start := "", -1
+	quote, escape := false, false
+	i := 0
+	add := func() {
+		params = append(params, strings.TrimSpace(current))
+		if start < 0 {
+			start = i
+		}
+		offsets = append(offsets, start)
+		current, start = "", -1
+	}
+	for _, c := range value {
+		switch {
+		case escape:
+			escape = false
+		case c == '\\' && quote:
+			escape = true
+		case c == '\'':
+			quote = !quote
+		case c == ',' && !quote:
+			add()
+			i++
+			continue
+		}
+		if start < 0 && strings.IndexRune(BLANKSPACES, c) < 0 {
+			start = i
+		}
+		current += string(c)
+		i++
+	}
+	add()
+	return params, offsets
+}
+
+func (lex *specLexer) stateError() error {
+	lex.errorf(lex.tokenPos(), "%s", lex.getToken().value)
+	return *lex.errors
+}
+
+func logMsg(v ...interface{}) {
+	if Debug {
+		log.Println(v...)
+	}
+}
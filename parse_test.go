@@ -0,0 +1,171 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package piglex
+
+import (
+	"strings"
+	"testing"
+)
+
+//
+// rulesOf parses a spec, returning its rules as "regexp -> actions"
+// and the diagnostics
+//
+func rulesOf(t *testing.T, source string) ([]string, []string) {
+	spec, err := ParseReader(strings.NewReader(source), &ParseOptions{Name: "t.pigl"})
+	diags := []string{}
+	if list, ok := err.(ErrorList); ok {
+		for _, diag := range list {
+			diags = append(diags, diag.Error())
+		}
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	rules := []string{}
+	for _, rule := range spec.Rules {
+		actions := []string{}
+		for _, action := range rule.Actions {
+			actions = append(actions, action.String())
+		}
+		rules = append(rules, rule.Pos.String()+" "+rule.Regexp+" -> "+strings.Join(actions, "; "))
+	}
+	return rules, diags
+}
+
+func TestRuleRegexps(t *testing.T) {
+	tests := []struct {
+		rules string
+		want  []string
+	}{
+		{"[^#x]+\treturn HASH\n", []string{"t.pigl:3:1 [^#x]+ -> return HASH"}},
+		{"#\treturn HASH\n", nil},
+		{"/\treturn SLASH\n", []string{"t.pigl:3:1 / -> return SLASH"}},
+		{"a/b\treturn AB\n", []string{"t.pigl:3:1 a/b -> return AB"}},
+		{"x//y\treturn X\n/[*]\treturn X\n", []string{"t.pigl:3:1 x//y -> return X", "t.pigl:4:1 /[*] -> return X"}},
+		{
+			"# comment\n// comment\n/* comment\n   on lines */\na#b\treturn A\n",
+			[]string{"t.pigl:7:1 a#b -> return A"},
+		},
+	}
+	for _, test := range tests {
+		source := "%token HASH, SLASH, AB, X, A\n%lex\n" + test.rules
+		rules, diags := rulesOf(t, source)
+		if len(diags) > 0 {
+			t.Errorf("%q: %q", test.rules, diags)
+		}
+		if strings.Join(rules, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%q: rules %q, want %q", test.rules, rules, test.want)
+		}
+	}
+}
+
+func TestCharActions(t *testing.T) {
+	tests := []struct {
+		rules string
+		want  []string
+		diags []string
+	}{
+		{"#\treturn '#'\n", nil, nil},
+		{"x#\treturn '#'\n", []string{"t.pigl:3:1 x# -> return '#'"}, nil},
+		{"/\treturn '/'\n", []string{"t.pigl:3:1 / -> return '/'"}, nil},
+		{"a\treturn '/' // slash\n", []string{"t.pigl:3:1 a -> return '/'"}, nil},
+		{"a\treturn '#' # hash\n", []string{"t.pigl:3:1 a -> return '#'"}, nil},
+		{"a\treturn '/' /* slash */\n", []string{"t.pigl:3:1 a -> return '/'"}, nil},
+		{"a\t{\n    return '#'\n    }\n", []string{"t.pigl:3:1 a -> return '#'"}, nil},
+		{"a\t{\n    return '/'\n    }\n", []string{"t.pigl:3:1 a -> return '/'"}, nil},
+		{"a\t{\n    return '/' // slash\n    }\n", []string{"t.pigl:3:1 a -> return '/'"}, nil},
+		{"a\t{ return '#' # hash\n    }\n", []string{"t.pigl:3:1 a -> return '#'"}, nil},
+	}
+	for _, test := range tests {
+		rules, diags := rulesOf(t, "%lex\n\n"+test.rules)
+		if strings.Join(diags, "\n") != strings.Join(test.diags, "\n") {
+			t.Errorf("%q: diagnostics %q, want %q", test.rules, diags, test.diags)
+		}
+		if strings.Join(rules, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%q: rules %q, want %q", test.rules, rules, test.want)
+		}
+	}
+}
+
+func TestYacc(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		tokens string
+		rules  []string
+		diags  []string
+	}{
+		{
+			"SYNTAX.md example",
+			"%token NUMBER, PLUS\n\n%yacc\n%left PLUS\n%%\nexpr: expr PLUS expr | NUMBER ;\n\n" +
+				"%lex\n[0-9]+\treturn NUMBER\n\\+\treturn PLUS\n",
+			"NUMBER PLUS",
+			[]string{"t.pigl:9:1 [0-9]+ -> return NUMBER", "t.pigl:10:1 \\+ -> return PLUS"},
+			nil,
+		},
+		{
+			"directives and code in a section",
+			"%token NUMBER\n%yacc\n%token EXPR\n%bogus\n%{\npackage main\n%}\n%%\n" +
+				"expr:\tNUMBER\t{ $$ = $1 }\n\t| '#' ;\n%%\nfunc main() {}\n" +
+				"%lex\n[0-9]+\treturn NUMBER\n",
+			"NUMBER",
+			[]string{"t.pigl:14:1 [0-9]+ -> return NUMBER"},
+			nil,
+		},
+		{
+			"sections between rules",
+			"%token NUMBER, PLUS\n%lex\n[0-9]+\treturn NUMBER\n%yacc\n%%\nexpr: NUMBER ;\n%lex\n\\+\treturn PLUS\n",
+			"NUMBER PLUS",
+			[]string{"t.pigl:3:1 [0-9]+ -> return NUMBER", "t.pigl:8:1 \\+ -> return PLUS"},
+			nil,
+		},
+		{
+			"PigYacc directives outside sections",
+			"%token NUMBER\n%type <num> expr\n%left PLUS, MINUS\n%right POW\n%nonassoc EQ\n%start expr\n" +
+				"%union {\n\tnum int\n}\n%expect 0\n%lex\n%type <num> term\n[0-9]+\treturn NUMBER\n",
+			"NUMBER",
+			[]string{"t.pigl:13:1 [0-9]+ -> return NUMBER"},
+			nil,
+		},
+		{
+			"unknown directive",
+			"%token NUMBER\n%bogus x\n%lex\n[0-9]+\treturn NUMBER\n",
+			"NUMBER",
+			[]string{"t.pigl:4:1 [0-9]+ -> return NUMBER"},
+			[]string{"t.pigl:2:1: unknown directive %bogus"},
+		},
+	}
+	for _, test := range tests {
+		rules, diags := rulesOf(t, test.source)
+		if strings.Join(diags, "\n") != strings.Join(test.diags, "\n") {
+			t.Errorf("%s: diagnostics %q, want %q", test.name, diags, test.diags)
+		}
+		if strings.Join(rules, "\n") != strings.Join(test.rules, "\n") {
+			t.Errorf("%s: rules %q, want %q", test.name, rules, test.rules)
+		}
+		spec, _ := ParseReader(strings.NewReader(test.source), &ParseOptions{Name: "t.pigl"})
+		tokens := []string{}
+		for _, decl := range spec.Tokens {
+			tokens = append(tokens, decl.Name)
+		}
+		if got := strings.Join(tokens, " "); got != test.tokens {
+			t.Errorf("%s: tokens %q, want %q", test.name, got, test.tokens)
+		}
+	}
+}
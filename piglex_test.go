@@ -0,0 +1,36 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package piglex_test
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/peergum/piglex/piglextest"
+)
+
+var update = flag.Bool("update", false, "update the golden files of testdata")
+
+//
+// TestSample checks sample.pigl against the golden files of testdata
+// (go test -update to write them)
+//
+func TestSample(t *testing.T) {
+	piglextest.Golden(t, "sample.pigl", *update, "testdata")
+}
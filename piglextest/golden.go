@@ -0,0 +1,212 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//
+// Package piglextest checks lexer specs against golden files: each
+// input file of a directory is run through the rules of the spec, and
+// the tokens found are compared with those of its .tokens file, as
+// printed by piglex tokenize. It is used by the piglex test command,
+// and from Go tests with Golden.
+//
+package piglextest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/peergum/piglex"
+)
+
+const (
+	GOLDEN_EXT = ".tokens" // extension of the golden files
+	MAX_DIFFS  = 10        // differences reported per input
+)
+
+//
+// Result is the outcome of the check of an input
+//
+type Result struct {
+	Input   string // input file
+	Golden  string // golden file, the input file + .tokens
+	Diff    string // differences with the golden file, empty if none
+	Updated bool   // golden file written (update mode)
+	Err     error  // error reading or writing the files
+}
+
+//
+// Failed tells if the input didn't give the tokens expected
+//
+func (result *Result) Failed() bool {
+	return result.Err != nil || result.Diff != ""
+}
+
+//
+// Check runs the spec on the inputs found in paths (directories, or
+// input files) and compares the tokens with the golden files. With
+// update, the golden files are written instead.
+//
+func Check(spec *piglex.Spec, paths []string, update bool) ([]*Result, error) {
+	inputs, err := Inputs(paths)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*Result, len(inputs))
+	for i, input := range inputs {
+		results[i] = checkInput(spec, input, update)
+	}
+	return results, nil
+}
+
+//
+// Inputs lists the input files of the paths: the files given, and the
+// files of the directories given but golden and hidden files
+//
+func Inputs(paths []string) ([]string, error) {
+	inputs := []string{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			inputs = append(inputs, path)
+			continue
+		}
+		files, err := ioutil.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			name := file.Name()
+			if file.IsDir() || strings.HasPrefix(name, ".") || filepath.Ext(name) == GOLDEN_EXT {
+				continue
+			}
+			inputs = append(inputs, filepath.Join(path, name))
+		}
+	}
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("no input file in %s", strings.Join(paths, ", "))
+	}
+	sort.Strings(inputs)
+	return inputs, nil
+}
+
+func checkInput(spec *piglex.Spec, input string, update bool) *Result {
+	result := &Result{
+		Input:  input,
+		Golden: input + GOLDEN_EXT,
+	}
+	source, err := os.Open(input)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer source.Close()
+	tokens, err := Tokenize(spec, filepath.Base(input), source)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	if update {
+		result.Err = ioutil.WriteFile(result.Golden, tokens, 0644)
+		result.Updated = result.Err == nil
+		return result
+	}
+	expected, err := ioutil.ReadFile(result.Golden)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Diff = Diff(expected, tokens)
+	return result
+}
+
+//
+// Tokenize runs the spec on a source and returns the tokens found,
+// as printed by piglex tokenize. An error of the lexer (no rule
+// matching) ends the tokens, on an error: line. Macro actions are not
+// run. Only reading errors are returned.
+//
+func Tokenize(spec *piglex.Spec, name string, source io.Reader) ([]byte, error) {
+	lexer, err := piglex.NewLexer(spec, source)
+	if err != nil {
+		return nil, err
+	}
+	lexer.Name = name
+	lexer.IgnoreMacros()
+	var buf bytes.Buffer
+	for {
+		token, err := lexer.Next()
+		if err == io.EOF {
+			break
+		}
+		if _, ok := err.(*piglex.Diagnostic); ok {
+			fmt.Fprintf(&buf, "error: %s\n", err)
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		token.Dump(&buf)
+	}
+	return buf.Bytes(), nil
+}
+
+//
+// Diff compares the lines of the expected and actual tokens, returning
+// the first differences (empty if there's none)
+//
+func Diff(expected, actual []byte) string {
+	want := splitLines(expected)
+	got := splitLines(actual)
+	var buf bytes.Buffer
+	diffs := 0
+	for i := 0; i < len(want) || i < len(got); i++ {
+		expectedLine, actualLine := lineAt(want, i), lineAt(got, i)
+		if expectedLine == actualLine {
+			continue
+		}
+		if diffs++; diffs > MAX_DIFFS {
+			fmt.Fprintf(&buf, "...\n")
+			break
+		}
+		fmt.Fprintf(&buf, "line %d:\n  expected: %s\n  got:      %s\n", i+1, expectedLine, actualLine)
+	}
+	return buf.String()
+}
+
+func lineAt(lines []string, i int) string {
+	if i < len(lines) {
+		return lines[i]
+	}
+	return "(end of file)"
+}
+
+func splitLines(text []byte) []string {
+	lines := strings.Split(string(text), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
@@ -0,0 +1,113 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package piglextest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	many := ""
+	for i := 1; i <= MAX_DIFFS+2; i++ {
+		many += fmt.Sprintf("line %d:\n  expected: a\n  got:      b\n", i)
+	}
+	many = many[:strings.Index(many, fmt.Sprintf("line %d:", MAX_DIFFS+1))] + "...\n"
+
+	tests := []struct {
+		expected string
+		actual   string
+		diff     string
+	}{
+		{"", "", ""},
+		{"a\nb\n", "a\nb\n", ""},
+		{"a\nb\n", "a\nb", ""},
+		{"a\nb\nc\n", "a\nx\nc\n", "line 2:\n  expected: b\n  got:      x\n"},
+		{"a\nb\n", "a\n", "line 2:\n  expected: b\n  got:      (end of file)\n"},
+		{"a\n", "a\nb\n", "line 2:\n  expected: (end of file)\n  got:      b\n"},
+		{"a\nb\n", "b\na\n", "line 1:\n  expected: a\n  got:      b\nline 2:\n  expected: b\n  got:      a\n"},
+		{strings.Repeat("a\n", MAX_DIFFS+2), strings.Repeat("b\n", MAX_DIFFS+2), many},
+	}
+	for _, test := range tests {
+		if diff := Diff([]byte(test.expected), []byte(test.actual)); diff != test.diff {
+			t.Errorf("Diff(%q, %q) = %q, want %q", test.expected, test.actual, diff, test.diff)
+		}
+	}
+}
+
+func TestInputs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "piglextest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"b.basic", "a.basic", "a.basic" + GOLDEN_EXT, ".hidden", "sub/c.basic"} {
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		paths  []string
+		inputs []string // relative to dir, nil for an error
+	}{
+		{[]string{"."}, []string{"a.basic", "b.basic"}},
+		{[]string{"sub", "."}, []string{"a.basic", "b.basic", "sub/c.basic"}},
+		{[]string{"b.basic", "a.basic" + GOLDEN_EXT}, []string{"a.basic" + GOLDEN_EXT, "b.basic"}},
+		{[]string{".hidden"}, []string{".hidden"}},
+		{[]string{"empty"}, nil},
+		{[]string{"missing"}, nil},
+		{[]string{}, nil},
+	}
+	for _, test := range tests {
+		paths := make([]string, len(test.paths))
+		for i, path := range test.paths {
+			paths[i] = filepath.Join(dir, path)
+		}
+		inputs, err := Inputs(paths)
+		if test.inputs == nil {
+			if err == nil {
+				t.Errorf("Inputs(%q) = %q, want an error", test.paths, inputs)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Inputs(%q): %s", test.paths, err)
+			continue
+		}
+		want := make([]string, len(test.inputs))
+		for i, input := range test.inputs {
+			want[i] = filepath.Join(dir, input)
+		}
+		if strings.Join(inputs, "\n") != strings.Join(want, "\n") {
+			t.Errorf("Inputs(%q) = %q, want %q", test.paths, inputs, want)
+		}
+	}
+}
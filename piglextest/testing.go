@@ -0,0 +1,70 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package piglextest
+
+import (
+	"testing"
+
+	"github.com/peergum/piglex"
+)
+
+//
+// Golden checks the spec file against the golden files of the paths,
+// failing t on errors in the spec and on any difference. With update,
+// the golden files are written instead:
+//
+//	var update = flag.Bool("update", false, "update the golden files")
+//
+//	func TestLexer(t *testing.T) {
+//		piglextest.Golden(t, "lexer.pigl", *update, "testdata")
+//	}
+//
+func Golden(t testing.TB, specFile string, update bool, paths ...string) {
+	t.Helper()
+	spec, err := piglex.ParseFile(specFile, nil)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if diags := piglex.Validate(spec, nil); diags.Errors() > 0 {
+		t.Fatalf("%s", diags)
+	}
+	GoldenSpec(t, spec, update, paths...)
+}
+
+//
+// GoldenSpec checks a parsed spec against the golden files of the
+// paths, as Golden
+//
+func GoldenSpec(t testing.TB, spec *piglex.Spec, update bool, paths ...string) {
+	t.Helper()
+	results, err := Check(spec, paths, update)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	for _, result := range results {
+		switch {
+		case result.Err != nil:
+			t.Errorf("%s: %s", result.Input, result.Err)
+		case result.Diff != "":
+			t.Errorf("%s: tokens differ from %s:\n%s", result.Input, result.Golden, result.Diff)
+		case result.Updated:
+			t.Logf("%s: updated", result.Golden)
+		}
+	}
+}
@@ -0,0 +1,463 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package piglex
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"unicode/utf8"
+)
+
+//
+// The runtime lexer interprets a parsed spec directly, with the same
+// semantics as a generated lexer: the longest match wins, the first
+// rule winning ties.
+//
+
+//
+// Token is a token found by a Lexer. IDs are numbered as in generated
+// lexers (256 for the first %token, unless imported from a token
+// table), a literal character being its own id.
+//
+type Token struct {
+	ID    int
+	Name  string
+	Text  string
+	Pos   Pos    // position in the input
+	State string // state the token was matched in
+}
+
+func (token Token) String() string {
+	return fmt.Sprintf("%s %s %q", token.Pos, token.Name, token.Text)
+}
+
+//
+// Dump writes the token on a line, as printed by piglex tokenize:
+// line:col NAME "text" [state]
+//
+func (token Token) Dump(w io.Writer) {
+	fmt.Fprintf(w, "%d:%d %s %q [%s]\n", token.Pos.Line, token.Pos.Col, token.Name, token.Text, token.State)
+}
+
+//
+// Macro is a Go callback run for a macro action, with the text matched
+// by the rule and the parameters of the call: token (int), value
+// (string), 'c' (rune)
+//
+type Macro func(lexer *Lexer, text string, args ...interface{}) error
+
+//
+// Lexer splits an input into tokens according to a spec
+//
+type Lexer struct {
+	Name     string           // name of the input, in positions
+	Macros   map[string]Macro // macro actions, by name
+	Coverage *Coverage        // counts the rules fired, if set
+	Wrap     func() io.Reader // next input, at the end of one (%option wrap)
+
+	spec     *Spec
+	state    string
+	matchers map[string]*ruleMatcher
+	tokens   map[string]int
+	in       input
+	line     int
+	col      int
+}
+
+//
+// ruleMatcher holds the rules of a state compiled into a single
+// leftmost-longest regexp, each rule being a group of the alternation.
+// full holds each rule alone, to break ties.
+//
+type ruleMatcher struct {
+	rules  []*LexRule
+	regexp *regexp.Regexp
+	groups []int
+	full   []*regexp.Regexp
+}
+
+//
+// input buffers the source from the start of the current token,
+// reading more as the matchers need it
+//
+type input struct {
+	source io.Reader
+	buffer []byte
+	start  int // start of the token in buffer
+	eof    bool
+	err    error
+}
+
+//
+// runeReader reads the input from the start of the token
+//
+type runeReader struct {
+	in  *input
+	pos int
+}
+
+//
+// NewLexer compiles the rules of a spec, to lex the source
+//
+func NewLexer(spec *Spec, source io.Reader) (*Lexer, error) {
+	if spec.NoUnicode {
+		return nil, fmt.Errorf("%%option nounicode is not supported by the runtime lexer")
+	}
+	lexer := &Lexer{
+		Macros:   map[string]Macro{},
+		spec:     spec,
+		state:    INITIAL_STATE,
+		matchers: map[string]*ruleMatcher{},
+		tokens:   map[string]int{},
+		in: input{
+			source: source,
+		},
+		line: 1,
+		col:  1,
+	}
+	for name, id := range spec.tokenIDs() {
+		lexer.tokens[name] = id
+	}
+	for _, decl := range spec.States {
+		rules := []*LexRule{}
+		for _, i := range spec.RulesFor(decl.Name) {
+			rules = append(rules, spec.Rules[i])
+		}
+		m, err := newRuleMatcher(rules)
+		if err != nil {
+			return nil, err
+		}
+		lexer.matchers[decl.Name] = m
+	}
+	return lexer, nil
+}
+
+//
+// IgnoreMacros registers a macro doing nothing for each macro called
+// by the rules and not registered yet
+//
+func (lexer *Lexer) IgnoreMacros() {
+	for _, rule := range lexer.spec.Rules {
+		for _, action := range rule.Actions {
+			if _, ok := lexer.Macros[action.Name]; action.Kind == ACTION_MACRO && !ok {
+				lexer.Macros[action.Name] = ignoreMacro
+			}
+		}
+	}
+}
+
+func ignoreMacro(lexer *Lexer, text string, args ...interface{}) error {
+	return nil
+}
+
+//
+// State returns the current state of the lexer
+//
+func (lexer *Lexer) State() string {
+	return lexer.state
+}
+
+//
+// SetState switches the lexer to a state of the spec
+//
+func (lexer *Lexer) SetState(state string) error {
+	if _, ok := lexer.matchers[state]; !ok {
+		return fmt.Errorf("unknown state %s", state)
+	}
+	lexer.state = state
+	return nil
+}
+
+//
+// Next returns the next token of the input, running the actions of
+// the rules matched without returning a token on the way. It returns
+// io.EOF at the end of the input, or of the last one with Wrap.
+//
+func (lexer *Lexer) Next() (Token, error) {
+	for {
+		if _, n := lexer.in.peek(0); n == 0 {
+			if lexer.in.err != nil {
+				return Token{}, lexer.in.err
+			}
+			if !lexer.spec.Wrap || lexer.Wrap == nil {
+				return Token{}, io.EOF
+			}
+			next := lexer.Wrap()
+			if next == nil {
+				return Token{}, io.EOF
+			}
+			lexer.in = input{source: next}
+			lexer.line, lexer.col = 1, 1
+			continue
+		}
+		m, ok := lexer.matchers[lexer.state]
+		if !ok {
+			return Token{}, fmt.Errorf("unknown state %s", lexer.state)
+		}
+		pos := lexer.pos()
+		rule, size := m.match(&lexer.in)
+		if rule == nil {
+			return Token{}, &Diagnostic{
+				Pos:      pos,
+				Severity: SEVERITY_ERROR,
+				Msg:      fmt.Sprintf("no rule matches %q in state %s", lexer.in.excerpt(20), lexer.state),
+			}
+		}
+		text := string(lexer.in.token(size))
+		lexer.in.advance(size)
+		lexer.move(text)
+		if lexer.spec.Debug {
+			log.Printf("%s [%s] rule %s: %q", pos, lexer.state, rule.Pos, text)
+		}
+		if lexer.Coverage != nil {
+			lexer.Coverage.fired(rule, lexer.state)
+		}
+
+		token, err := lexer.run(rule, text, pos)
+		if err != nil {
+			return Token{}, err
+		}
+		if token != nil {
+			return *token, nil
+		}
+	}
+}
+
+//
+// run executes the actions of a rule, returning the token if any
+//
+func (lexer *Lexer) run(rule *LexRule, text string, pos Pos) (*Token, error) {
+	state := lexer.state
+	for _, action := range rule.Actions {
+		switch action.Kind {
+		case ACTION_STATE:
+			if err := lexer.SetState(action.Name); err != nil {
+				return nil, fmt.Errorf("%s: %s", action.Pos, err)
+			}
+		case ACTION_RETURN:
+			id, ok := lexer.tokenID(action)
+			if !ok {
+				return nil, fmt.Errorf("%s: unknown token %s", action.Pos, action.Name)
+			}
+			return &Token{
+				ID:    id,
+				Name:  action.Name,
+				Text:  text,
+				Pos:   pos,
+				State: state,
+			}, nil
+		case ACTION_MACRO:
+			macro, ok := lexer.Macros[action.Name]
+			if !ok {
+				return nil, fmt.Errorf("%s: no macro %s", action.Pos, action.Name)
+			}
+			if err := macro(lexer, text, lexer.params(rule, action, text)...); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return nil, nil
+}
+
+//
+// params returns the parameters of a macro call: the token of the rule
+// (0 if none) for token, the text for value, a rune for 'c'
+//
+func (lexer *Lexer) params(rule *LexRule, action *Action, text string) []interface{} {
+	args := make([]interface{}, len(action.Params))
+	for i, param := range action.Params {
+		switch param.Kind {
+		case PARAM_TOKEN:
+			args[i] = 0
+			if ret := rule.Return(); ret != nil {
+				args[i], _ = lexer.tokenID(ret)
+			}
+		case PARAM_VALUE:
+			args[i] = text
+		case PARAM_CHAR:
+			args[i] = param.Char
+		}
+	}
+	return args
+}
+
+//
+// tokenID returns the id of the token returned by an action
+//
+func (lexer *Lexer) tokenID(action *Action) (int, bool) {
+	if action.Char != 0 {
+		return int(action.Char), true
+	}
+	id, ok := lexer.tokens[action.Name]
+	return id, ok
+}
+
+func (lexer *Lexer) pos() Pos {
+	return Pos{
+		File: lexer.Name,
+		Line: lexer.line,
+		Col:  lexer.col,
+	}
+}
+
+//
+// move updates the line and column after a token
+//
+func (lexer *Lexer) move(text string) {
+	for _, c := range text {
+		if c == '\n' {
+			lexer.line++
+			lexer.col = 1
+		} else {
+			lexer.col++
+		}
+	}
+}
+
+func newRuleMatcher(rules []*LexRule) (*ruleMatcher, error) {
+	m := &ruleMatcher{
+		rules:  rules,
+		groups: make([]int, len(rules)),
+		full:   make([]*regexp.Regexp, len(rules)),
+	}
+	if len(rules) == 0 {
+		return m, nil
+	}
+	pattern := ""
+	group := 1
+	for i, rule := range rules {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", rule.Pos, err)
+		}
+		m.full[i] = regexp.MustCompile("^(?:" + rule.Pattern + ")$")
+		if i > 0 {
+			pattern += "|"
+		}
+		pattern += "(" + rule.Pattern + ")"
+		m.groups[i] = group
+		group += 1 + re.NumSubexp()
+	}
+	m.regexp = regexp.MustCompile("^(?:" + pattern + ")")
+	m.regexp.Longest()
+	return m, nil
+}
+
+//
+// match returns the first rule matching the longest prefix of the
+// input, and the length of the match
+//
+func (m *ruleMatcher) match(in *input) (*LexRule, int) {
+	if m.regexp == nil {
+		return nil, 0
+	}
+	loc := m.regexp.FindReaderSubmatchIndex(&runeReader{in: in})
+	if loc == nil || loc[1] == 0 {
+		return nil, 0
+	}
+	token := in.token(loc[1])
+	for i, group := range m.groups {
+		if loc[2*group] >= 0 || m.full[i].Match(token) {
+			return m.rules[i], loc[1]
+		}
+	}
+	return nil, 0
+}
+
+//
+// peek decodes the rune at i bytes from the start of the token, reading
+// more of the source if needed. It returns a size of 0 at the end.
+//
+func (in *input) peek(i int) (rune, int) {
+	for !in.eof && len(in.buffer)-(in.start+i) < utf8.UTFMax {
+		in.fill()
+	}
+	pos := in.start + i
+	if pos >= len(in.buffer) {
+		return 0, 0
+	}
+	if c := in.buffer[pos]; c < utf8.RuneSelf {
+		return rune(c), 1
+	}
+	return utf8.DecodeRune(in.buffer[pos:])
+}
+
+//
+// fill drops what's before the token and reads more of the source
+//
+func (in *input) fill() {
+	if in.start > 0 {
+		n := copy(in.buffer, in.buffer[in.start:])
+		in.buffer = in.buffer[:n]
+		in.start = 0
+	}
+	if len(in.buffer) == cap(in.buffer) {
+		buffer := make([]byte, len(in.buffer), 2*cap(in.buffer)+4096)
+		copy(buffer, in.buffer)
+		in.buffer = buffer
+	}
+	n, err := in.source.Read(in.buffer[len(in.buffer):cap(in.buffer)])
+	in.buffer = in.buffer[:len(in.buffer)+n]
+	if err != nil {
+		in.eof = true
+		if err != io.EOF {
+			in.err = err
+		}
+	}
+}
+
+//
+// token returns the first size bytes from the start of the token
+//
+func (in *input) token(size int) []byte {
+	return in.buffer[in.start : in.start+size]
+}
+
+//
+// excerpt returns up to max runes from the start of the token
+//
+func (in *input) excerpt(max int) []byte {
+	size := 0
+	for i := 0; i < max; i++ {
+		_, n := in.peek(size)
+		if n == 0 {
+			break
+		}
+		size += n
+	}
+	return in.token(size)
+}
+
+//
+// advance moves the start of the next token
+//
+func (in *input) advance(size int) {
+	in.start += size
+}
+
+func (reader *runeReader) ReadRune() (rune, int, error) {
+	r, n := reader.in.peek(reader.pos)
+	if n == 0 {
+		return 0, 0, io.EOF
+	}
+	reader.pos += n
+	return r, n, nil
+}
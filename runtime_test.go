@@ -0,0 +1,149 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package piglex
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+//
+// tokenize runs the runtime lexer of a spec over an input, returning
+// the tokens as "NAME text"
+//
+func tokenize(t *testing.T, spec *Spec, input string) []string {
+	lexer, err := NewLexer(spec, strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lexer.Name = "input"
+	tokens := []string{}
+	for {
+		token, err := lexer.Next()
+		if err == io.EOF {
+			return tokens
+		}
+		if err != nil {
+			return append(tokens, err.Error())
+		}
+		tokens = append(tokens, token.Name+" "+token.Text)
+	}
+}
+
+func TestCaseless(t *testing.T) {
+	rules := "%token PRINT, NAME, KEY\n%lex\n" +
+		"print\treturn PRINT\n" +
+		"(?-i)KEY\treturn KEY\n" +
+		"[a-z]+\treturn NAME\n" +
+		"[ ]+\t\n"
+	input := "print PRINT Print KEY key Key"
+	tests := []struct {
+		name     string
+		source   string
+		caseless bool
+		want     string
+	}{
+		{
+			"case-sensitive",
+			rules,
+			false,
+			`PRINT print|input:1:7: no rule matches "PRINT Print KEY key " in state _INIT`,
+		},
+		{
+			"-i",
+			rules,
+			true,
+			"PRINT print|PRINT PRINT|PRINT Print|KEY KEY|NAME key|NAME Key",
+		},
+		{
+			"%option caseless",
+			"%option caseless\n" + rules,
+			false,
+			"PRINT print|PRINT PRINT|PRINT Print|KEY KEY|NAME key|NAME Key",
+		},
+	}
+	for _, test := range tests {
+		spec, err := ParseReader(strings.NewReader(test.source), &ParseOptions{Name: "t.pigl", Caseless: test.caseless})
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if got := strings.Join(tokenize(t, spec, input), "|"); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	rules := "%token WORD\n%lex\n[a-z]+\treturn WORD\n[ \\n]+\t\n"
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{rules, "a:1:1 WORD ab|a:2:1 WORD cd"},
+		{"%option wrap\n" + rules, "a:1:1 WORD ab|a:2:1 WORD cd|b:1:1 WORD ef|b:1:4 WORD gh|c:1:2 WORD ij"},
+	}
+	for _, test := range tests {
+		spec, err := ParseReader(strings.NewReader(test.source), &ParseOptions{Name: "t.pigl"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		lexer, err := NewLexer(spec, strings.NewReader("ab\ncd"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lexer.Name = "a"
+		inputs := [][2]string{{"b", "ef gh"}, {"empty", ""}, {"c", " ij"}}
+		lexer.Wrap = func() io.Reader {
+			if len(inputs) == 0 {
+				return nil
+			}
+			input := inputs[0]
+			inputs = inputs[1:]
+			lexer.Name = input[0]
+			return strings.NewReader(input[1])
+		}
+		tokens := []string{}
+		for {
+			token, err := lexer.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tokens = append(tokens, fmt.Sprintf("%s %s %s", token.Pos, token.Name, token.Text))
+		}
+		if got := strings.Join(tokens, "|"); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.source, got, test.want)
+		}
+	}
+}
+
+func TestNoUnicodeRuntime(t *testing.T) {
+	spec, err := ParseReader(strings.NewReader("%option nounicode\n%token A\n%lex\na\treturn A\n"), &ParseOptions{Name: "t.pigl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewLexer(spec, strings.NewReader("a"))
+	if err == nil || err.Error() != "%option nounicode is not supported by the runtime lexer" {
+		t.Errorf("got %v, want nounicode not supported", err)
+	}
+}
@@ -1,31 +1,50 @@
+// Code generated by piglex from test.pigl. DO NOT EDIT.
+
 package main
 
 const (
-	TOKEN_TEST1 = 256 + iota
+	TOKEN_TEST1 TokenID = 256 + iota
 	TOKEN_TEST2
 )
 
 const (
-	STATE_INIT = iota
+	STATE_INIT StateID = iota
+)
+
+const (
+	DEBUG    = false
+	WRAP     = false
+	UNICODE  = true
+	COVERAGE = false
 )
 
 var (
 	rules = map[string][]*Rule{
-		{
-			"_INIT": {
-				"test1",
-				action_input,
-			}
-		}
+		"_INIT": {
+			{`test1`, action_0, "test.pigl:8:1"},
+			{`test2`, action_1, "test.pigl:9:1"},
+		},
+	}
+	dfaTables  = map[string]*dfaTable{}
+	tokenNames = map[TokenID]string{
+		TOKEN_TEST1: "TEST1",
+		TOKEN_TEST2: "TEST2",
+	}
+	TokenByName = map[string]TokenID{
+		"TEST1": TOKEN_TEST1,
+		"TEST2": TOKEN_TEST2,
+	}
+	stateNames = []string{
+		"_INIT",
 	}
 )
 
-func action_test1(value string) error {
-	fm.Println("TEST1")
-	return nil
+// test1
+func action_0(lexer *Lexer, token *Token) error {
+	return lexer.emit(TOKEN_TEST1, token)
 }
 
-func action_test2(value string) error {
-	fm.Println("TEST2")
-	return nil
+// test2
+func action_1(lexer *Lexer, token *Token) error {
+	return lexer.emit(TOKEN_TEST2, token)
 }
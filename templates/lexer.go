@@ -1,112 +1,501 @@
 package main
 
 import (
-	"bufio"
 	//"errors"
-	"flag"
 	"fmt"
 	"io"
 	//"log"
 	"os"
-	"path/filepath"
 	"regexp"
 	//"strings"
+	"sort"
+	"strconv"
+	"unicode/utf8"
 )
 
 const (
 	VERSION = "0.1"
-	DEBUG   = false
 )
 
 type Rule struct {
 	regexp string
-	action func(string) error
+	action func(*Lexer, *Token) error
+	pos    string // position of the rule in the .pigl file
+}
+
+//
+// Pos is a position in the input: Offset in bytes from the start,
+// Line and Col (in runes) from 1
+//
+type Pos struct {
+	Offset int
+	Line   int
+	Col    int
+}
+
+//
+// TokenID is the id of a token (TOKEN_ constants), StateID the id
+// of a state (STATE_ constants)
+//
+type TokenID int
+type StateID int
+
+//
+// Token is a token found by the lexer, from Pos to EndPos (excluded),
+// in State
+//
+type Token struct {
+	ID     TokenID
+	Text   string
+	Pos    Pos
+	EndPos Pos
+	State  string
+}
+
+//
+// matcher finds the rule matching the longest prefix of the input,
+// returning its index in the rules of the state and the match length.
+// When several rules match the same length, the first one wins.
+//
+type matcher interface {
+	match(in *input) (int, int)
+}
+
+//
+// regexpMatcher holds all the rules of a state compiled into a single
+// regexp, each rule being a group of the alternation. full holds each
+// rule alone, to break ties.
+//
+type regexpMatcher struct {
+	regexp *regexp.Regexp
+	groups []int
+	full   []*regexp.Regexp
+}
+
+//
+// dfaTable is a DFA generated by piglex (dfa backend): runes map to
+// classes, base[state]+class is the index of the next state in trans
+//
+type dfaTable struct {
+	classes  []dfaRange
+	nclasses int
+	base     []int32
+	trans    []int32
+	accept   []int32
+	latin    []int32
+}
+
+type dfaRange struct {
+	lo, hi rune
+	class  int32
+}
+
+//
+// input buffers the source from the start of the current token,
+// reading more as the matchers need it
+//
+type input struct {
+	source io.Reader
+	buffer []byte
+	start  int // start of the token in buffer
+	pos    Pos // position of the token in the source
+	eof    bool
+	err    error
+}
+
+//
+// runeReader reads the input from the start of the token,
+// for the regexp matchers
+//
+type runeReader struct {
+	in  *input
+	pos int
+}
+
+type Lexer struct {
+	Wrap func() io.Reader // next input, at the end of one (WRAP)
+
+	state string
+	in    input
+	token *Token           // emitted by the last action
+	hits  map[string][]int // times each rule fired, per state (COVERAGE)
 }
 
 var (
-	app      string  = filepath.Base(os.Args[0])
-	fVersion *bool   = flag.Bool("v", false, "Show Version")
-	fName    *string = flag.String("f", "", "File to parse")
-	flags    []string
-	args     []string
+	matchers = map[string]matcher{}
 )
 
 func init() {
-	flag.Parse()
-	args = flag.Args()
+	for state, list := range rules {
+		if table, ok := dfaTables[state]; ok {
+			table.init()
+			matchers[state] = table
+		} else {
+			matchers[state] = newMatcher(list)
+		}
+	}
+}
+
+//
+// newMatcher compiles the rules of a state once, as a leftmost-longest
+// alternation: (rule1)|(rule2)|...
+//
+func newMatcher(rules []*Rule) *regexpMatcher {
+	m := &regexpMatcher{
+		groups: make([]int, len(rules)),
+		full:   make([]*regexp.Regexp, len(rules)),
+	}
+	pattern := ""
+	group := 1
+	for i, rule := range rules {
+		re := regexp.MustCompile(rule.regexp)
+		m.full[i] = regexp.MustCompile("^(?:" + rule.regexp + ")$")
+		if i > 0 {
+			pattern += "|"
+		}
+		pattern += "(" + rule.regexp + ")"
+		m.groups[i] = group
+		group += 1 + re.NumSubexp()
+	}
+	m.regexp = regexp.MustCompile("^(?:" + pattern + ")")
+	m.regexp.Longest()
+	return m
+}
 
-	if *fVersion {
-		showVersion()
+//
+// match returns the rule matching the longest prefix of input, and
+// the length of the match. The regexp reads the input as long as the
+// match can be extended. It tells which rule matched, but not the
+// first of the rules giving the same length: the rules before it are
+// checked against the token.
+//
+func (m *regexpMatcher) match(in *input) (int, int) {
+	loc := m.regexp.FindReaderSubmatchIndex(&runeReader{in: in})
+	if loc == nil || loc[1] == 0 {
+		return -1, 0
 	}
+	token := in.token(loc[1])
+	for i, group := range m.groups {
+		if loc[2*group] >= 0 {
+			return i, loc[1]
+		}
+		if m.full[i].Match(token) {
+			return i, loc[1]
+		}
+	}
+	return -1, 0
 }
 
-func showVersion() {
-	fmt.Printf("%s, version %s", app, VERSION)
-	os.Exit(0)
+//
+// init builds the class lookup table of the first 256 runes
+//
+func (table *dfaTable) init() {
+	latin := make([]int32, 256)
+	for r := range latin {
+		latin[r] = table.class(rune(r))
+	}
+	table.latin = latin
 }
 
-func main() {
-	fmt.Printf("Welcome to %s.\n", app)
+func (table *dfaTable) class(r rune) int32 {
+	if r < rune(len(table.latin)) {
+		return table.latin[r]
+	}
+	i := sort.Search(len(table.classes), func(i int) bool { return table.classes[i].hi >= r })
+	if i < len(table.classes) && table.classes[i].lo <= r {
+		return table.classes[i].class
+	}
+	return -1
+}
 
-	if *fName == "" {
-		fmt.Println("No file.")
-		os.Exit(1)
+//
+// match runs the DFA as long as it can, and backs up to the last
+// accepting state
+//
+func (table *dfaTable) match(in *input) (int, int) {
+	state := int32(0)
+	rule, size := -1, 0
+	for i := 0; ; {
+		r, n := in.peek(i)
+		if n == 0 {
+			break
+		}
+		if !UNICODE {
+			// runes are bytes
+			r, n = rune(in.buffer[in.start+i]), 1
+		}
+		class := table.class(r)
+		if class < 0 {
+			break
+		}
+		state = table.trans[table.base[state]+class]
+		if state < 0 {
+			break
+		}
+		i += n
+		if accept := table.accept[state]; accept >= 0 {
+			rule, size = int(accept), i
+		}
 	}
-	var source *os.File
-	var err error
+	return rule, size
+}
 
-	if source, err = os.Open(*fName); err != nil {
-		fmt.Printf("Can't open file %s", *fName)
-		os.Exit(1)
+//
+// peek decodes the rune at i bytes from the start of the token, reading
+// more of the source if needed. It returns a size of 0 at the end.
+//
+func (in *input) peek(i int) (rune, int) {
+	for !in.eof && len(in.buffer)-(in.start+i) < utf8.UTFMax {
+		in.fill()
+	}
+	pos := in.start + i
+	if pos >= len(in.buffer) {
+		return 0, 0
 	}
-	defer source.Close()
+	if c := in.buffer[pos]; c < utf8.RuneSelf {
+		return rune(c), 1
+	}
+	return utf8.DecodeRune(in.buffer[pos:])
+}
 
-	loop(bufio.NewReader(source))
+//
+// fill drops what's before the token and reads more of the source
+//
+func (in *input) fill() {
+	if in.start > 0 {
+		n := copy(in.buffer, in.buffer[in.start:])
+		in.buffer = in.buffer[:n]
+		in.start = 0
+	}
+	if len(in.buffer) == cap(in.buffer) {
+		buffer := make([]byte, len(in.buffer), 2*cap(in.buffer)+4096)
+		copy(buffer, in.buffer)
+		in.buffer = buffer
+	}
+	n, err := in.source.Read(in.buffer[len(in.buffer):cap(in.buffer)])
+	in.buffer = in.buffer[:len(in.buffer)+n]
+	if err != nil {
+		in.eof = true
+		if err != io.EOF {
+			in.err = err
+		}
+	}
+}
 
+//
+// token returns the first size bytes from the start of the token
+//
+func (in *input) token(size int) []byte {
+	return in.buffer[in.start : in.start+size]
 }
 
-func loop(reader *bufio.Reader) error {
-	position := 0
-	bestMatch := 0
-	current := ""
-	var bestRule *Rule
-	for state != "_END" {
-		if position > 0 {
-			current = current[position:]
-			position = 0
-			bestRule = nil
-		} else {
-			next, _, err := reader.ReadRune()
-			if err != nil {
-				if err != io.EOF {
-					panic("Read Error @ [" + current + "]")
-				}
-				if bestRule != nil {
-					bestRule.call(current)
+//
+// advance moves the start of the next token, after size bytes
+//
+func (in *input) advance(size int) {
+	for _, c := range in.token(size) {
+		switch {
+		case c == '\n':
+			in.pos.Line++
+			in.pos.Col = 1
+		case UNICODE && !utf8.RuneStart(c):
+			// continuation byte of a rune
+		default:
+			in.pos.Col++
+		}
+	}
+	in.start += size
+	in.pos.Offset += size
+}
+
+func (reader *runeReader) ReadRune() (rune, int, error) {
+	r, n := reader.in.peek(reader.pos)
+	if n == 0 {
+		return 0, 0, io.EOF
+	}
+	reader.pos += n
+	return r, n, nil
+}
+
+func NewLexer(source io.Reader) *Lexer {
+	lexer := &Lexer{
+		state: "_INIT",
+		in:    newInput(source),
+	}
+	if COVERAGE {
+		lexer.hits = map[string][]int{}
+		for state, list := range rules {
+			lexer.hits[state] = make([]int, len(list))
+		}
+	}
+	return lexer
+}
+
+func newInput(source io.Reader) input {
+	return input{
+		source: source,
+		pos:    Pos{Line: 1, Col: 1},
+	}
+}
+
+//
+// Next returns the next token, running the actions of the rules
+// matched on the way. It returns io.EOF at the end of the input.
+//
+func (lexer *Lexer) Next() (Token, error) {
+	for {
+		if _, n := lexer.in.peek(0); n == 0 {
+			if lexer.in.err != nil {
+				return Token{}, lexer.in.err
+			}
+			if !WRAP || lexer.Wrap == nil {
+				return Token{}, io.EOF
+			}
+			next := lexer.Wrap()
+			if next == nil {
+				return Token{}, io.EOF
+			}
+			lexer.in = newInput(next)
+			continue
+		}
+		m, ok := matchers[lexer.state]
+		if !ok {
+			return Token{}, fmt.Errorf("unknown state %s", lexer.state)
+		}
+		index, size := m.match(&lexer.in)
+		pos := lexer.in.pos
+		if index < 0 {
+			end := 0
+			for end < 20 {
+				_, n := lexer.in.peek(end)
+				if n == 0 {
 					break
 				}
+				end += n
 			}
-			current += string(next)
+			return Token{}, fmt.Errorf("SYNTAX ERROR @ %d:%d [%s]", pos.Line, pos.Col, lexer.in.token(end))
 		}
-		for _, rule := range rules[state] {
-			regexp := regexp.MustCompilePOSIX("^" + rule.regexp + "$")
-			match := regexp.FindString(current)
-			if len(match) > bestMatch {
-				bestMatch = len(match)
-				bestRule = rule
+		token := &Token{
+			Text:  string(lexer.in.token(size)),
+			Pos:   pos,
+			State: lexer.state,
+		}
+		if DEBUG {
+			fmt.Fprintf(os.Stderr, "%d:%d [%s] rule %d: %q\n", pos.Line, pos.Col, lexer.state, index, token.Text)
+		}
+		if COVERAGE {
+			lexer.hits[lexer.state][index]++
+		}
+		lexer.in.advance(size)
+		token.EndPos = lexer.in.pos
+
+		lexer.token = nil
+		if err := rules[lexer.state][index].call(lexer, token); err != nil {
+			return Token{}, err
+		}
+		if lexer.token != nil {
+			return *lexer.token, nil
+		}
+	}
+}
+
+func (rule *Rule) call(lexer *Lexer, token *Token) error {
+	return rule.action(lexer, token)
+}
+
+//
+// Coverage writes the times each rule fired per state, then the rules
+// that never fired and the states never entered (COVERAGE), as
+// piglex coverage does
+//
+func (lexer *Lexer) Coverage(w io.Writer) {
+	if !COVERAGE {
+		fmt.Fprintf(w, "coverage not enabled\n")
+		return
+	}
+	fired := map[string]bool{}
+	for _, state := range stateNames {
+		if lexer.entered(state) {
+			fmt.Fprintf(w, "%s\n", state)
+		} else {
+			fmt.Fprintf(w, "%s (never entered)\n", state)
+		}
+		for i, rule := range rules[state] {
+			fmt.Fprintf(w, "  %10d  %s  %s\n", lexer.hits[state][i], rule.pos, rule.regexp)
+			if lexer.hits[state][i] > 0 {
+				fired[rule.pos] = true
 			}
 		}
-		if bestRule == nil {
-			if bestMatch == 0 {
-				panic("SYNTAX ERROR @ [" + current + "]")
+	}
+	unfired := []*Rule{}
+	seen := map[string]bool{}
+	for _, state := range stateNames {
+		for _, rule := range rules[state] {
+			if !seen[rule.pos] {
+				seen[rule.pos] = true
+				if !fired[rule.pos] {
+					unfired = append(unfired, rule)
+				}
 			}
-			position = bestMatch
-			bestRule.call(current[0:position])
 		}
 	}
+	fmt.Fprintf(w, "%d of %d rule(s) fired\n", len(seen)-len(unfired), len(seen))
+	for _, rule := range unfired {
+		fmt.Fprintf(w, "never fired: %s  %s\n", rule.pos, rule.regexp)
+	}
+	for _, state := range stateNames {
+		if !lexer.entered(state) {
+			fmt.Fprintf(w, "never entered: %s\n", state)
+		}
+	}
+}
+
+//
+// entered tells if a rule fired in a state
+//
+func (lexer *Lexer) entered(state string) bool {
+	for _, hits := range lexer.hits[state] {
+		if hits > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+//
+// emit is called by the actions returning a token
+//
+func (lexer *Lexer) emit(id TokenID, token *Token) error {
+	token.ID = id
+	lexer.token = token
 	return nil
 }
 
-func (rule *Rule) call(value string) error {
-	return rule.action(value)
+func (token Token) String() string {
+	return fmt.Sprintf("%d:%d %s %q", token.Pos.Line, token.Pos.Col, token.ID, token.Text)
+}
+
+//
+// TokenName returns the name of a token: PRINT for TOKEN_PRINT,
+// the quoted character for ids below 256
+//
+func TokenName(id TokenID) string {
+	if name, ok := tokenNames[id]; ok {
+		return name
+	}
+	if id >= 0 && id < 256 {
+		return strconv.QuoteRune(rune(id))
+	}
+	return strconv.Itoa(int(id))
+}
+
+func (id TokenID) String() string {
+	return TokenName(id)
+}
+
+func (id StateID) String() string {
+	if id >= 0 && int(id) < len(stateNames) {
+		return stateNames[id]
+	}
+	return strconv.Itoa(int(id))
 }
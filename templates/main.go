@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	app      string  = filepath.Base(os.Args[0])
+	fVersion *bool   = flag.Bool("v", false, "Show Version")
+	fName    *string = flag.String("f", "", "File to parse")
+	fTime    *bool   = flag.Bool("t", false, "Report throughput instead of printing tokens")
+	flags    []string
+	args     []string
+)
+
+func init() {
+	flag.Parse()
+	args = flag.Args()
+
+	if *fVersion {
+		showVersion()
+	}
+}
+
+func showVersion() {
+	fmt.Printf("%s, version %s", app, VERSION)
+	os.Exit(0)
+}
+
+func main() {
+	fmt.Printf("Welcome to %s.\n", app)
+
+	if *fName == "" {
+		fmt.Println("No file.")
+		os.Exit(1)
+	}
+	var source *os.File
+	var err error
+
+	if source, err = os.Open(*fName); err != nil {
+		fmt.Printf("Can't open file %s", *fName)
+		os.Exit(1)
+	}
+	defer source.Close()
+
+	input := &counter{reader: bufio.NewReader(source)}
+	lexer := NewLexer(input)
+	if WRAP {
+		// the other files given as arguments follow
+		lexer.Wrap = func() io.Reader {
+			source.Close()
+			for len(args) > 0 {
+				name := args[0]
+				args = args[1:]
+				if source, err = os.Open(name); err == nil {
+					input.reader = bufio.NewReader(source)
+					return input
+				}
+				fmt.Printf("Can't open file %s\n", name)
+			}
+			return nil
+		}
+	}
+
+	start := time.Now()
+	err = loop(lexer, *fTime)
+	if COVERAGE {
+		lexer.Coverage(os.Stderr)
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if *fTime {
+		elapsed := time.Since(start)
+		fmt.Fprintf(os.Stderr, "%d bytes in %s: %.2f MB/s\n", input.size, elapsed,
+			float64(input.size)/elapsed.Seconds()/1e6)
+	}
+}
+
+//
+// loop prints the tokens of the input, unless quiet
+//
+func loop(lexer *Lexer, quiet bool) error {
+	for {
+		token, err := lexer.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !quiet {
+			fmt.Println(token)
+		}
+	}
+}
+
+//
+// counter counts the bytes read, for -t
+//
+type counter struct {
+	reader io.Reader
+	size   int
+}
+
+func (c *counter) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.size += n
+	return n, err
+}
@@ -0,0 +1,212 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package piglex
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+//
+// The token table is shared with the parser generator (PigYacc), so
+// both number the tokens the same way. Literal characters are their
+// own id (as in yacc), named tokens start at 256.
+//
+
+const (
+	FIRST_TOKEN_ID = 256
+)
+
+//
+// TokenTable lists the tokens of a spec with their ids
+//
+type TokenTable struct {
+	Tokens []TokenEntry `json:"tokens"`
+}
+
+//
+// TokenEntry is a named token (Name) or a literal character (Char)
+//
+type TokenEntry struct {
+	Name string `json:"name,omitempty"`
+	ID   int    `json:"id"`
+	Char string `json:"char,omitempty"`
+}
+
+//
+// ReadTokenTable reads a token table in JSON
+//
+func ReadTokenTable(reader io.Reader) (*TokenTable, error) {
+	table := &TokenTable{}
+	if err := json.NewDecoder(reader).Decode(table); err != nil {
+		return nil, fmt.Errorf("token table: %s", err)
+	}
+	return table, nil
+}
+
+//
+// WriteJSON writes the table in JSON, one token per line
+//
+func (table *TokenTable) WriteJSON(w io.Writer) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "{\n  \"tokens\": [")
+	for i, entry := range table.Tokens {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			fmt.Fprintf(&buf, ",")
+		}
+		fmt.Fprintf(&buf, "\n    %s", line)
+	}
+	fmt.Fprintf(&buf, "\n  ]\n}\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+//
+// TokenTable returns the tokens of the spec, named tokens first in
+// order of declaration, then the literal characters returned by the
+// rules in order of id
+//
+func (spec *Spec) TokenTable() *TokenTable {
+	table := &TokenTable{
+		Tokens: []TokenEntry{},
+	}
+	ids := spec.tokenIDs()
+	seen := map[string]bool{}
+	for _, decl := range spec.Tokens {
+		if seen[decl.Name] {
+			continue
+		}
+		seen[decl.Name] = true
+		table.Tokens = append(table.Tokens, TokenEntry{Name: decl.Name, ID: ids[decl.Name]})
+	}
+	for _, char := range spec.charTokens() {
+		table.Tokens = append(table.Tokens, TokenEntry{ID: int(char), Char: string(char)})
+	}
+	return table
+}
+
+//
+// ImportTokens numbers the tokens of the spec as in a table made by
+// the parser generator. Tokens of the spec missing from the table
+// get the ids left free.
+//
+func (spec *Spec) ImportTokens(table *TokenTable) error {
+	ids := map[string]int{}
+	names := map[int]string{}
+	for _, entry := range table.Tokens {
+		if entry.Name == "" {
+			continue
+		}
+		if entry.ID < FIRST_TOKEN_ID {
+			return fmt.Errorf("token table: id %d of %s is reserved for characters", entry.ID, entry.Name)
+		}
+		if name, ok := names[entry.ID]; ok && name != entry.Name {
+			return fmt.Errorf("token table: id %d given to both %s and %s", entry.ID, name, entry.Name)
+		}
+		if id, ok := ids[entry.Name]; ok && id != entry.ID {
+			return fmt.Errorf("token table: %s has ids %d and %d", entry.Name, id, entry.ID)
+		}
+		ids[entry.Name] = entry.ID
+		names[entry.ID] = entry.Name
+	}
+	spec.TokenIDs = ids
+	return nil
+}
+
+//
+// tokenIDs numbers the declared tokens: imported ids first, then
+// from 256 in order of declaration, skipping the ids of the table
+//
+func (spec *Spec) tokenIDs() map[string]int {
+	ids := map[string]int{}
+	taken := map[int]bool{}
+	for _, id := range spec.TokenIDs {
+		taken[id] = true
+	}
+	for _, decl := range spec.Tokens {
+		if id, ok := spec.TokenIDs[decl.Name]; ok {
+			ids[decl.Name] = id
+		}
+	}
+	next := FIRST_TOKEN_ID
+	for _, decl := range spec.Tokens {
+		if _, ok := ids[decl.Name]; ok {
+			continue
+		}
+		for taken[next] {
+			next++
+		}
+		ids[decl.Name] = next
+		next++
+	}
+	return ids
+}
+
+//
+// charTokens returns the literal characters returned by the rules
+//
+func (spec *Spec) charTokens() []rune {
+	seen := map[rune]bool{}
+	chars := []rune{}
+	for _, rule := range spec.Rules {
+		if action := rule.Return(); action != nil && action.Char != 0 && !seen[action.Char] {
+			seen[action.Char] = true
+			chars = append(chars, action.Char)
+		}
+	}
+	sort.Sort(runeList(chars))
+	return chars
+}
+
+//
+// GenerateTokens produces a Go file declaring the token ids of the
+// spec, for the parser
+//
+func GenerateTokens(spec *Spec) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by piglex from %s. DO NOT EDIT.\n\n", filepath.Base(spec.File))
+	fmt.Fprintf(&buf, "package %s\n\n", packageName(spec))
+	table := spec.TokenTable()
+	fmt.Fprintf(&buf, "const (\n")
+	for _, entry := range table.Tokens {
+		if entry.Name != "" {
+			fmt.Fprintf(&buf, "%s = %d\n", tokenConst(entry.Name), entry.ID)
+		}
+	}
+	fmt.Fprintf(&buf, ")\n\n")
+	fmt.Fprintf(&buf, "var TokenNames = map[int]string{\n")
+	for _, entry := range table.Tokens {
+		if entry.Name != "" {
+			fmt.Fprintf(&buf, "%s: %q,\n", tokenConst(entry.Name), entry.Name)
+		} else {
+			fmt.Fprintf(&buf, "%d: %q,\n", entry.ID, strconv.QuoteRune(rune(entry.ID)))
+		}
+	}
+	fmt.Fprintf(&buf, "}\n")
+	return finishSource(buf.Bytes(), spec.Prefix)
+}
@@ -0,0 +1,57 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package piglex
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestTokenIDs(t *testing.T) {
+	tests := []struct {
+		tokens   string
+		imported []TokenEntry
+		ids      string
+	}{
+		{"A, B, C", nil, "A=256 B=257 C=258"},
+		{"A, B, C", []TokenEntry{{Name: "A", ID: 256}, {Name: "C", ID: 258}}, "A=256 B=257 C=258"},
+		{"A, B, C", []TokenEntry{{Name: "B", ID: 256}}, "A=257 B=256 C=258"},
+		{"A, B, C", []TokenEntry{{Name: "C", ID: 300}}, "A=256 B=257 C=300"},
+		{"A, B", []TokenEntry{{Name: "X", ID: 256}, {Name: "B", ID: 258}}, "A=257 B=258"},
+		{"A, B, C", []TokenEntry{{Name: "B", ID: 257}, {Name: "X", ID: 258}}, "A=256 B=257 C=259"},
+	}
+	for _, test := range tests {
+		source := fmt.Sprintf("%%token %s\n%%lex\n", test.tokens)
+		spec, err := ParseReader(strings.NewReader(source), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := spec.ImportTokens(&TokenTable{Tokens: test.imported}); err != nil {
+			t.Fatal(err)
+		}
+		ids := []string{}
+		for _, entry := range spec.TokenTable().Tokens {
+			ids = append(ids, fmt.Sprintf("%s=%d", entry.Name, entry.ID))
+		}
+		if got := strings.Join(ids, " "); got != test.ids {
+			t.Errorf("%%token %s with %v: %s, want %s", test.tokens, test.imported, got, test.ids)
+		}
+	}
+}
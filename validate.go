@@ -0,0 +1,252 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package piglex
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// validation checks
+const (
+	CHECK_UNDECLARED  = "undeclared"
+	CHECK_DUPLICATE   = "duplicate"
+	CHECK_UNUSED      = "unused"
+	CHECK_NAMING      = "naming"
+	CHECK_UNREACHABLE = "unreachable"
+)
+
+var (
+	tokenName = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+	stateName = regexp.MustCompile(`^_[A-Z][A-Z0-9_]*$`)
+
+	severityNames = map[string]int{
+		"error":   SEVERITY_ERROR,
+		"warning": SEVERITY_WARNING,
+		"ignore":  SEVERITY_IGNORE,
+	}
+)
+
+//
+// Checks gives the severity of each validation check. It can be set
+// from the command line: -W unused=error,naming=ignore
+//
+type Checks map[string]int
+
+func DefaultChecks() Checks {
+	return Checks{
+		CHECK_UNDECLARED:  SEVERITY_ERROR,
+		CHECK_DUPLICATE:   SEVERITY_ERROR,
+		CHECK_UNUSED:      SEVERITY_WARNING,
+		CHECK_NAMING:      SEVERITY_WARNING,
+		CHECK_UNREACHABLE: SEVERITY_WARNING,
+	}
+}
+
+func (checks Checks) String() string {
+	list := []string{}
+	for check, severity := range checks {
+		for name, value := range severityNames {
+			if value == severity {
+				list = append(list, check+"="+name)
+			}
+		}
+	}
+	sort.Strings(list)
+	return strings.Join(list, ",")
+}
+
+//
+// Set changes the severity of checks; "all" applies to every check
+//
+func (checks Checks) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("expecting check=error|warning|ignore, got %q", item)
+		}
+		severity, ok := severityNames[parts[1]]
+		if !ok {
+			return fmt.Errorf("unknown severity %q", parts[1])
+		}
+		if parts[0] == "all" {
+			for check := range checks {
+				checks[check] = severity
+			}
+			continue
+		}
+		if _, ok := checks[parts[0]]; !ok {
+			return fmt.Errorf("unknown check %q", parts[0])
+		}
+		checks[parts[0]] = severity
+	}
+	return nil
+}
+
+//
+// validator collects the diagnostics of the semantic checks
+//
+type validator struct {
+	spec   *Spec
+	checks Checks
+	diags  ErrorList
+}
+
+func (v *validator) report(check string, pos Pos, format string, args ...interface{}) {
+	severity := v.checks[check]
+	if severity == SEVERITY_IGNORE {
+		return
+	}
+	v.diags.Add(pos, severity, format+" ["+check+"]", args...)
+}
+
+//
+// validate checks the references and declarations of a parsed spec
+//
+func validate(spec *Spec, checks Checks) ErrorList {
+	v := &validator{
+		spec:   spec,
+		checks: checks,
+	}
+	v.checkDeclarations("token", spec.Tokens, tokenName)
+	v.checkDeclarations("state", spec.States, stateName)
+	v.checkDefs()
+	v.checkScopes()
+	v.checkActions()
+	v.checkUnused()
+
+	v.diags.setExcerpts(spec.sources)
+	v.diags.Sort()
+	return v.diags
+}
+
+func (v *validator) checkDeclarations(kind string, decls []*Decl, convention *regexp.Regexp) {
+	seen := map[string]*Decl{}
+	for _, decl := range decls {
+		if first, ok := seen[decl.Name]; ok {
+			v.report(CHECK_DUPLICATE, decl.Pos, "%s %s already declared at %s", kind, decl.Name, first.Pos)
+			continue
+		}
+		seen[decl.Name] = decl
+		if !convention.MatchString(decl.Name) {
+			if kind == "state" {
+				v.report(CHECK_NAMING, decl.Pos, "state %s should be uppercase and start with an underscore", decl.Name)
+			} else {
+				v.report(CHECK_NAMING, decl.Pos, "token %s should be uppercase", decl.Name)
+			}
+		}
+	}
+	if kind == "token" {
+		for _, decl := range decls {
+			if state := v.spec.state(decl.Name); state != nil {
+				v.report(CHECK_DUPLICATE, decl.Pos, "%s is declared both as a token and a state", decl.Name)
+			}
+		}
+	}
+}
+
+//
+// checkDefs reports definitions given twice or never used
+//
+func (v *validator) checkDefs() {
+	seen := map[string]*Definition{}
+	used := map[string]bool{}
+	for _, def := range v.spec.Defs {
+		if first, ok := seen[def.Name]; ok {
+			v.report(CHECK_DUPLICATE, def.Pos, "definition %s already given at %s", def.Name, first.Pos)
+			continue
+		}
+		seen[def.Name] = def
+		for _, name := range defNames(def.Regexp) {
+			used[name] = true
+		}
+	}
+	for _, rule := range v.spec.Rules {
+		for _, name := range defNames(rule.Regexp) {
+			used[name] = true
+		}
+	}
+	for _, def := range v.spec.Defs {
+		if !used[def.Name] && seen[def.Name] == def {
+			v.report(CHECK_UNUSED, def.Pos, "definition %s is never used", def.Name)
+		}
+	}
+}
+
+//
+// checkScopes checks the states named by %only and %except
+//
+func (v *validator) checkScopes() {
+	for _, directive := range v.spec.Directives {
+		if directive.Name != "only" && directive.Name != "except" {
+			continue
+		}
+		for i, name := range directive.Args {
+			if v.spec.state(name) == nil {
+				v.report(CHECK_UNDECLARED, directive.ArgPos(i), "%%%s: undeclared state %s", directive.Name, name)
+			}
+		}
+	}
+}
+
+func (v *validator) checkActions() {
+	for _, rule := range v.spec.Rules {
+		for i, action := range rule.Actions {
+			switch action.Kind {
+			case ACTION_RETURN:
+				if action.Char == 0 && v.spec.token(action.Name) == nil {
+					v.report(CHECK_UNDECLARED, action.Pos, "return: undeclared token %s", action.Name)
+				}
+				if i < len(rule.Actions)-1 {
+					v.report(CHECK_UNREACHABLE, rule.Actions[i+1].Pos, "action after return is never run")
+				}
+			case ACTION_STATE:
+				if v.spec.state(action.Name) == nil {
+					v.report(CHECK_UNDECLARED, action.Pos, "state: undeclared state %s", action.Name)
+				}
+			}
+		}
+	}
+}
+
+//
+// checkUnused reports tokens never returned and states never entered
+//
+func (v *validator) checkUnused() {
+	used := map[string]bool{
+		INITIAL_STATE: true,
+	}
+	for _, rule := range v.spec.Rules {
+		for _, action := range rule.Actions {
+			used[action.Name] = true
+		}
+	}
+	for _, decl := range v.spec.Tokens {
+		if !used[decl.Name] {
+			v.report(CHECK_UNUSED, decl.Pos, "token %s is never returned", decl.Name)
+		}
+	}
+	for _, decl := range v.spec.States {
+		if !used[decl.Name] {
+			v.report(CHECK_UNUSED, decl.Pos, "state %s is never entered", decl.Name)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+//
+// PigLex
+// ------
+// Copyright 2014 Philippe Hilger (PeerGum)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package piglex
+
+import (
+	"strings"
+	"testing"
+)
+
+// a spec failing every check
+const validateSpec = `%token NUM, NUM, word, _S, UNUSED
+%state _S, lower, _NEVER
+%define D [0-9]
+%define D [a-z]
+%define X x
+%lex
+%only _NOPE
+{D}+	return NUM
+w	{
+    return word
+    state _S
+    }
+s	state _UNDECL
+q	return MISSING
+`
+
+func TestValidate(t *testing.T) {
+	all := []string{
+		"t.pigl:1:13: token NUM already declared at t.pigl:1:8 [duplicate]",
+		"t.pigl:1:18: token word should be uppercase [naming]",
+		"t.pigl:1:24: token _S should be uppercase [naming]",
+		"t.pigl:1:24: _S is declared both as a token and a state [duplicate]",
+		"t.pigl:1:28: token UNUSED is never returned [unused]",
+		"t.pigl:2:12: state lower should be uppercase and start with an underscore [naming]",
+		"t.pigl:2:12: state lower is never entered [unused]",
+		"t.pigl:2:19: state _NEVER is never entered [unused]",
+		"t.pigl:4:9: definition D already given at t.pigl:3:9 [duplicate]",
+		"t.pigl:5:9: definition X is never used [unused]",
+		"t.pigl:7:7: %only: undeclared state _NOPE [undeclared]",
+		"t.pigl:11:5: action after return is never run [unreachable]",
+		"t.pigl:13:3: state: undeclared state _UNDECL [undeclared]",
+		"t.pigl:14:3: return: undeclared token MISSING [undeclared]",
+	}
+	tests := []struct {
+		checks string
+		errors []int // indexes in all, reported as errors
+		warns  []int // indexes in all, reported as warnings
+	}{
+		{"", []int{0, 3, 8, 10, 12, 13}, []int{1, 2, 4, 5, 6, 7, 9, 11}},
+		{"all=error", []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13}, nil},
+		{"all=ignore", nil, nil},
+		{"all=ignore,duplicate=warning", nil, []int{0, 3, 8}},
+		{"all=ignore,naming=error", []int{1, 2, 5}, nil},
+		{"all=ignore,unused=warning", nil, []int{4, 6, 7, 9}},
+		{"all=ignore,unreachable=error", []int{11}, nil},
+		{"undeclared=warning,duplicate=ignore,unused=ignore,naming=ignore",
+			nil, []int{10, 11, 12, 13}},
+	}
+	spec, err := ParseReader(strings.NewReader(validateSpec), &ParseOptions{Name: "t.pigl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		checks := DefaultChecks()
+		if test.checks != "" {
+			if err := checks.Set(test.checks); err != nil {
+				t.Fatalf("Set(%q): %s", test.checks, err)
+			}
+		}
+		want := map[string]int{}
+		for _, i := range test.errors {
+			want[all[i]] = SEVERITY_ERROR
+		}
+		for _, i := range test.warns {
+			want[all[i]] = SEVERITY_WARNING
+		}
+		diags := Validate(spec, checks)
+		got := map[string]int{}
+		for _, diag := range diags {
+			msg := strings.Replace(diag.Error(), "warning: ", "", 1)
+			got[msg] = diag.Severity
+			if severity, ok := want[msg]; !ok {
+				t.Errorf("%q: unexpected %s", test.checks, diag.Error())
+			} else if severity != diag.Severity {
+				t.Errorf("%q: %s has severity %d, want %d", test.checks, msg, diag.Severity, severity)
+			}
+		}
+		for msg := range want {
+			if _, ok := got[msg]; !ok {
+				t.Errorf("%q: missing %s", test.checks, msg)
+			}
+		}
+		if got, want := diags.Errors(), len(test.errors); got != want {
+			t.Errorf("%q: %d error(s), want %d", test.checks, got, want)
+		}
+	}
+}
+
+func TestChecksSet(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+		err   string
+	}{
+		{"all=error", "duplicate=error,naming=error,undeclared=error,unreachable=error,unused=error", ""},
+		{"unused=ignore, naming=error", "duplicate=error,naming=error,undeclared=error,unreachable=warning,unused=ignore", ""},
+		{"all=ignore,undeclared=warning", "duplicate=ignore,naming=ignore,undeclared=warning,unreachable=ignore,unused=ignore", ""},
+		{"shadow=error", "", `unknown check "shadow"`},
+		{"unused=fatal", "", `unknown severity "fatal"`},
+		{"all=loud", "", `unknown severity "loud"`},
+		{"unused", "", `expecting check=error|warning|ignore, got "unused"`},
+	}
+	for _, test := range tests {
+		checks := DefaultChecks()
+		err := checks.Set(test.value)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("Set(%q): error %v, want %q", test.value, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Set(%q): %s", test.value, err)
+		} else if got := checks.String(); got != test.want {
+			t.Errorf("Set(%q) = %s, want %s", test.value, got, test.want)
+		}
+	}
+}